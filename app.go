@@ -3,31 +3,232 @@ package main
 import (
 	"image"
 	"log"
+	"time"
 
 	"github.com/JoelOtter/termloop"
+	"github.com/bmatsuo/cimoj/crunch"
 )
 
 // CrunchConfig defines the crunching board.
 type CrunchConfig struct {
 	Player           string
-	Survival         SurvivalDifficulty
+	Survival         crunch.SurvivalDifficulty
 	NumCol           int
 	ColVSpace        int
 	ColSpace         int
 	ColDepth         int
 	CritterSizeSmall int
 	CritterSizeLarge int
+
+	// Difficulty, Theme, Keys, and ChainSpeed name the difficulty profile,
+	// color theme, key map, and the speed of chain reactions.  Survival must
+	// agree with Difficulty.  Locale is the language of the game's text.
+	Difficulty string
+	Theme      string
+	Keys       string
+	ChainSpeed string
+	Locale     string
+
+	// KeyBindings replace the bindings of controls in the Keys preset.
+	KeyBindings Bindings
+	keys        *KeyMap // the preset named by Keys with KeyBindings applied
+
+	// SettingsFile is where the options screen saves settings.  If
+	// SettingsFile is empty settings are not saved.
+	SettingsFile string
+
+	// Clock determines the timing of games.  If Clock is nil games are
+	// timed by the wall clock.
+	Clock crunch.Clock
+
+	// Seed seeds the random number generator of every game.  If Seed is zero
+	// a new seed is chosen for each game.
+	Seed int64
+
+	// ReplayDir is a directory where replays of finished games are saved.  If
+	// ReplayDir is empty replays are not saved.
+	ReplayDir string
+
+	// Bot names the computer player which plays every game instead of the
+	// player.  Games played by a bot are not recorded.  If Bot is empty the
+	// player plays.
+	Bot string
+
+	// Versus is played instead of survival when it is not nil.  The player
+	// plays against a second player sharing the keyboard.
+	Versus *VersusConfig
+
+	// left is the column of the screen at which games are drawn.  It is only
+	// nonzero for the second game of a versus game.
+	left int
+
+	// Puzzle is played instead of survival when it is not nil.  Puzzle should
+	// be set with setPuzzle so the board matches the puzzle.
+	Puzzle *crunch.Puzzle
+
+	// Pack is the level pack containing Puzzle, if any.
+	Pack *LevelPack
+}
+
+// setPuzzle makes p the game played with conf and sizes the board for it.  If
+// p is nil the board is left as is and survival is played.
+func (conf *CrunchConfig) setPuzzle(p *crunch.Puzzle) {
+	conf.Puzzle = p
+	if p != nil {
+		conf.NumCol = len(p.Vines)
+		conf.ColDepth = p.ColDepth()
+	}
+}
+
+func (conf *CrunchConfig) gameType() string {
+	if conf.Puzzle != nil {
+		return "puzzle"
+	}
+	if conf.Versus != nil {
+		return "versus"
+	}
+	return "survival"
 }
 
 func (conf *CrunchConfig) boardSize() image.Point {
 	return image.Point{
 		X: conf.NumCol*conf.CritterSizeLarge + (conf.NumCol+1)*conf.ColSpace,
-		Y: (conf.ColDepth+1)*(conf.CritterSizeLarge+conf.ColVSpace) + conf.CritterSizeLarge, // extra depth for death indication -- below vine
+		Y: conf.rowY(conf.ColDepth) + conf.rowHeight(), // extra depth for death indication -- below vine
+	}
+}
+
+// rowHeight returns the number of rows of the board each bug on a vine
+// occupies, not including the space between bugs.
+func (conf *CrunchConfig) rowHeight() int {
+	return critterHeight(conf.CritterSizeLarge)
+}
+
+// rowY returns the top row of the board occupied by the bug at depth j of a
+// vine.  A bug at depth ColDepth has overflowed the vine.
+func (conf *CrunchConfig) rowY(j int) int {
+	return 1 + j*(conf.rowHeight()+conf.ColVSpace)
+}
+
+// colX returns the column of the board on which vine i is drawn.  The
+// player's position to the right of the last vine is beside the border.
+func (conf *CrunchConfig) colX(i int) int {
+	if i >= conf.NumCol {
+		return conf.boardSize().X
+	}
+	return 1 + conf.ColSpace + conf.CritterSizeLarge/2 + i*(conf.ColSpace+conf.CritterSizeLarge)
+}
+
+// boardTop returns the number of rows above the canopy of the board.  In
+// survival they hold the countdown to the next row of bugs, the preview of the
+// row, and a row into which stomping pulls the preview.
+func (conf *CrunchConfig) boardTop() int {
+	if conf.Puzzle != nil {
+		return 1
+	}
+	return conf.rowHeight() + 2
+}
+
+// groundY returns the row of the board on which the player and the ground
+// are drawn.
+func (conf *CrunchConfig) groundY() int {
+	return conf.boardSize().Y
+}
+
+// The information panel to the right of the board.
+const (
+	panelWidth  = 36
+	panelHeight = 16
+)
+
+// gameWidth returns the number of columns taken by one game, its board and
+// information panel.
+func (conf *CrunchConfig) gameWidth() int {
+	return conf.boardSize().X + 10 + panelWidth
+}
+
+// screenSize returns the size of the terminal needed to display a game with
+// conf, including the information panel to the right of the board.  A versus
+// game shows two games side by side.
+func (conf *CrunchConfig) screenSize() image.Point {
+	size := conf.boardSize()
+	size.X = conf.gameWidth()
+	if conf.Versus != nil {
+		size.X *= 2
+	}
+	size.Y += 2 + conf.boardTop()
+	if size.Y < panelHeight {
+		size.Y = panelHeight
+	}
+	return size
+}
+
+func (conf *CrunchConfig) colors() ColorMap {
+	theme := lookupTheme(conf.Theme)
+	if theme == nil {
+		return defaultColorMap
+	}
+	return theme.Colors
+}
+
+func (conf *CrunchConfig) keyMap() *KeyMap {
+	if conf.keys != nil && conf.keys.Name == conf.Keys {
+		return conf.keys
+	}
+	m := lookupKeyMap(conf.Keys)
+	if m == nil {
+		return lookupKeyMap(defaultKeyMap)
+	}
+	return m
+}
+
+// setKeys makes the named key map, with conf.KeyBindings applied, control
+// games.  Conf is unchanged if an error is returned.
+func (conf *CrunchConfig) setKeys(name string) error {
+	m, err := buildKeyMap(name, conf.KeyBindings)
+	if err != nil {
+		return err
+	}
+	conf.Keys = name
+	conf.keys = m
+	return nil
+}
+
+func (conf *CrunchConfig) clock() crunch.Clock {
+	if conf.Clock == nil {
+		return crunch.RealClock{}
 	}
+	return conf.Clock
+}
+
+func (conf *CrunchConfig) seed() int64 {
+	if conf.Seed == 0 {
+		return time.Now().UnixNano()
+	}
+	return conf.Seed
+}
+
+// crunchConfig returns the configuration of the game engine.
+func (conf *CrunchConfig) crunchConfig() *crunch.Config {
+	return &crunch.Config{
+		NumCol:    conf.NumCol,
+		ColDepth:  conf.ColDepth,
+		Survival:  conf.Survival,
+		Seed:      conf.seed(),
+		Puzzle:    conf.Puzzle,
+		ChainStep: conf.chainStep(),
+	}
+}
+
+func (conf *CrunchConfig) chainStep() time.Duration {
+	speed := lookupChainSpeed(conf.ChainSpeed)
+	if speed == nil {
+		return 0
+	}
+	return speed.Step
 }
 
 func (conf *CrunchConfig) colLength() int {
-	return conf.ColDepth * (conf.CritterSizeLarge + conf.ColVSpace)
+	return conf.ColDepth * (conf.rowHeight() + conf.ColVSpace)
 }
 
 // CrunchApp represents the top-level application, a session which may involve
@@ -38,22 +239,34 @@ type CrunchApp struct {
 	config  *CrunchConfig
 	menu    *CrunchMenu
 	current *CrunchGame
+	versus  *VersusGame
 	scoreDB ScoreDB
+	replay  *Replay
+	scores  *HighScoreView
+	options *OptionsView
+
+	packs     []*LevelPack
+	progress  *ProgressFile
+	packMenu  *PackMenu
+	pack      *LevelPack // the pack of the current game
+	packLevel int
 }
 
 // NewCrunchApp creates a new CrunchApp using a static config that can be
-// repeatedly played.
-func NewCrunchApp(game *termloop.Game, config *CrunchConfig, scores ScoreDB, showMenu bool) *CrunchApp {
+// repeatedly played.  If replay is not nil every game started by the app is a
+// playback of replay and the menu is never shown.
+func NewCrunchApp(game *termloop.Game, config *CrunchConfig, scores ScoreDB, showMenu bool, replay *Replay) *CrunchApp {
 	app := &CrunchApp{
 		game:    game,
 		config:  config,
 		scoreDB: scores,
+		replay:  replay,
 	}
 
-	if showMenu {
+	if showMenu && replay == nil {
 		app.menu = NewCrunchMenu(config)
 	} else {
-		app.current = app.createNewGame()
+		app.startGame()
 	}
 
 	game.Screen().AddEntity(app)
@@ -61,6 +274,26 @@ func NewCrunchApp(game *termloop.Game, config *CrunchConfig, scores ScoreDB, sho
 	return app
 }
 
+// startGame starts a game with the app's config, which is a versus game if
+// the config has a second player.
+func (app *CrunchApp) startGame() {
+	if app.config.Versus != nil {
+		app.versus = app.createVersusGame(app.config)
+		return
+	}
+	app.current = app.createNewGame(app.config)
+}
+
+// SetLevelPacks makes packs available to play from the menu.  Progress through
+// the packs is recorded in progress.
+func (app *CrunchApp) SetLevelPacks(packs []*LevelPack, progress *ProgressFile) {
+	app.packs = packs
+	app.progress = progress
+	if app.menu != nil {
+		app.menu.setPacks(packs, progress)
+	}
+}
+
 // Start starts the application/game.
 func (app *CrunchApp) Start() {
 	app.game.Start()
@@ -68,19 +301,53 @@ func (app *CrunchApp) Start() {
 
 // Draw implements termloop.Drawable
 func (app *CrunchApp) Draw(screen *termloop.Screen) {
+	if app.versus != nil {
+		app.versus.Draw(screen)
+		return
+	}
 	if app.current != nil {
 		app.current.Draw(screen)
 		return
 	}
+	if app.scores != nil {
+		app.scores.Draw(screen)
+		return
+	}
+	if app.options != nil {
+		app.options.Draw(screen)
+		return
+	}
+	if app.packMenu != nil {
+		app.packMenu.Draw(screen)
+		return
+	}
 	app.menu.Draw(screen)
 }
 
 // Tick implements termloop.Drawable
 func (app *CrunchApp) Tick(event termloop.Event) {
+	if app.versus != nil {
+		if !app.versus.Finished() {
+			app.versus.Tick(event)
+		} else if app.config.Versus.net == nil && event.Type == termloop.EventKey && event.Key == termloop.KeyEnter {
+			// A networked game cannot be played again on the same connection.
+			app.versus = app.createVersusGame(app.config)
+		}
+		return
+	}
 	if app.current != nil && !app.current.Finished() {
 		app.current.Tick(event)
 		return
 	}
+	if app.current == nil && app.options != nil {
+		// The options screen handles its own Enter and Esc keys.
+		app.options.Tick(event)
+		if app.options.Done() {
+			app.options = nil
+			app.menu.refresh(app.packs, app.progress)
+		}
+		return
+	}
 
 	if event.Type == termloop.EventKey { // Is it a keyboard event?
 		switch event.Key {
@@ -89,24 +356,93 @@ func (app *CrunchApp) Tick(event termloop.Event) {
 				// Just let the old game get garbage collected, it will stop
 				// recieved events and draw calls, so the only real worry is lag in
 				// the subsequent game.
-				app.current = app.createNewGame()
+				if app.pack != nil {
+					// Solving a level moves on to the next one.
+					i := app.packLevel
+					if app.current.engine.Won() && i+1 < len(app.pack.Levels) {
+						i++
+					}
+					app.playPackLevel(app.pack, i)
+					return
+				}
+				app.current = app.createNewGame(app.config)
+				return
+			}
+			if app.scores != nil {
+				return
+			}
+			if app.packMenu != nil {
+				i, ok := app.packMenu.GetSelection()
+				if ok {
+					app.playPackLevel(app.packMenu.pack, i)
+				}
 				return
 			}
 			menuItem, _ := app.menu.GetSelection()
-			if menuItem == 0 {
-				app.current = app.createNewGame()
+			switch menuItem {
+			case 0:
+				app.startGame()
+			case 1:
+				app.scores = NewHighScoreView(app.config, app.scoreDB)
+			case 2:
+				app.options = NewOptionsView(app.config)
+			default:
+				if pack := app.menu.SelectedPack(); pack != nil && pack.Compatible() {
+					app.openPackMenu(pack)
+				}
 			}
 			return
+		case termloop.KeyEsc:
+			if app.current != nil && app.pack != nil {
+				app.openPackMenu(app.pack)
+				return
+			}
+			if app.current == nil && app.scores != nil {
+				app.scores = nil
+				return
+			}
+			if app.current == nil && app.packMenu != nil {
+				app.packMenu = nil
+				app.menu.refresh(app.packs, app.progress)
+				return
+			}
 		}
 	}
 
 	// Pass the keypress onto the menu when the app has not intercepted it by
 	// this point.
+	if app.scores != nil {
+		app.scores.Tick(event)
+		return
+	}
+	if app.packMenu != nil {
+		app.packMenu.Tick(event)
+		return
+	}
 	app.menu.Tick(event)
 }
 
-func (app *CrunchApp) createNewGame() *CrunchGame {
-	size := app.config.boardSize()
+func (app *CrunchApp) openPackMenu(pack *LevelPack) {
+	progress := app.progress.Progress(app.config.Player, pack.Manifest.Name)
+	app.current = nil
+	app.pack = nil
+	app.packMenu = NewPackMenu(pack, progress)
+}
+
+// playPackLevel starts a game of level i of pack.  The app's config is left
+// untouched so the board size of other games is unchanged.
+func (app *CrunchApp) playPackLevel(pack *LevelPack, i int) {
+	config := *app.config
+	config.Versus = nil
+	config.setPuzzle(pack.Levels[i])
+	config.Pack = pack
+	app.pack = pack
+	app.packLevel = i
+	app.current = app.createNewGame(&config)
+}
+
+func (app *CrunchApp) createNewGame(config *CrunchConfig) *CrunchGame {
+	size := config.boardSize()
 	log.Printf("size=[%d, %d] new game", size.X, size.Y)
 
 	cellLevel := &termloop.Cell{
@@ -133,7 +469,7 @@ func (app *CrunchApp) createNewGame() *CrunchGame {
 	}
 
 	board := termloop.NewBaseLevel(*cellLevel)
-	board.SetOffset(2, 1)
+	board.SetOffset(config.left+2, config.boardTop())
 
 	border := termloop.NewEntity(0, 0, size.X+2, size.Y+2)
 	for i := 0; i < size.X+2; i++ {
@@ -151,14 +487,19 @@ func (app *CrunchApp) createNewGame() *CrunchGame {
 		Fg: termloop.ColorGreen,
 		Ch: '|',
 	}
-	for i := 0; i < app.config.NumCol; i++ {
-		posX := 1 + app.config.ColSpace + app.config.CritterSizeLarge/2 + i*(app.config.ColSpace+app.config.CritterSizeLarge)
-		column := termloop.NewEntity(posX, 1, 1, app.config.colLength())
+	for i := 0; i < config.NumCol; i++ {
+		column := termloop.NewEntity(config.colX(i), 1, 1, config.colLength())
 		column.Fill(cellVine)
 		board.AddEntity(column)
 	}
 
-	crunch := NewCrunchGame(app.config, app.scoreDB, board)
+	crunch := NewCrunchGame(config, app.scoreDB, board)
+	crunch.progress = app.progress
+	if app.replay != nil {
+		crunch.playReplay(app.replay)
+	} else if config.Bot != "" {
+		crunch.playBot(config.Bot)
+	}
 	level.AddEntity(crunch)
 
 	return crunch
@@ -0,0 +1,93 @@
+// Package bot implements computer players for Cimoj.  A Player looks at a
+// crunch.Snapshot of the board before each tick and chooses the controls to
+// apply, the same controls a person presses on the keyboard.  Players can be
+// watched in the terminal or used to benchmark difficulty profiles.
+package bot
+
+import (
+	"math/rand"
+	"strings"
+
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+// Player chooses the controls applied to a game before each tick.  Play must
+// not retain s after it returns.
+type Player interface {
+	Play(s *crunch.Snapshot) []crunch.PlayerControl
+}
+
+// Preset is a named Player which can be chosen by the user.  New creates a
+// player for the game with the given seed.
+type Preset struct {
+	Name string
+	New  func(seed int64) Player
+}
+
+// Default is the name of the player used when none is chosen.
+const Default = "greedy"
+
+// Presets are the players that can be chosen.
+var Presets = []*Preset{
+	{"idle", func(seed int64) Player { return Idle{} }},
+	{"random", func(seed int64) Player { return NewRandom(seed) }},
+	{"greedy", func(seed int64) Player { return &Greedy{Period: GreedyPeriod} }},
+}
+
+// Lookup returns the preset with the given name or nil if there is none.
+func Lookup(name string) *Preset {
+	for _, p := range Presets {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Names returns the names that can be chosen, separated by commas.
+func Names() string {
+	names := make([]string, len(Presets))
+	for i := range Presets {
+		names[i] = Presets[i].Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// Idle never does anything.  It measures how long the bugs take to overflow
+// a vine on their own.
+type Idle struct{}
+
+// Play implements Player.
+func (Idle) Play(s *crunch.Snapshot) []crunch.PlayerControl {
+	return nil
+}
+
+// Random moves, grabs, and spits at random several times a second.
+type Random struct {
+	rand  *rand.Rand
+	input []crunch.PlayerControl
+}
+
+// NewRandom returns a Random player whose choices are determined by seed.
+func NewRandom(seed int64) *Random {
+	return &Random{rand: rand.New(rand.NewSource(seed))}
+}
+
+// randomControls are the controls chosen by a Random player.  Grabbing is
+// listed twice so that the player spends less time wandering.
+var randomControls = []crunch.PlayerControl{
+	crunch.PlayerMoveLeft,
+	crunch.PlayerMoveRight,
+	crunch.PlayerGrabSpit,
+	crunch.PlayerGrabSpit,
+}
+
+// Play implements Player.
+func (p *Random) Play(s *crunch.Snapshot) []crunch.PlayerControl {
+	const period = 10 // ticks between controls
+	if s.Tick%period != 0 {
+		return nil
+	}
+	p.input = append(p.input[:0], randomControls[p.rand.Intn(len(randomControls))])
+	return p.input
+}
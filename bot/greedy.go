@@ -0,0 +1,250 @@
+package bot
+
+import (
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+// GreedyPeriod is the default number of ticks between the controls of a
+// Greedy player, about as fast as a practiced person can press keys.
+const GreedyPeriod = 8
+
+// Values used by a Greedy player to weigh its choices.
+const (
+	greedyFeed   = 10  // a bug is eaten
+	greedyChain  = 100 // a bug is filled, setting off a chain or explosion
+	greedyLinked = 10  // each bug caught in a chain
+	greedyStep   = 2   // each column walked
+	greedyPoison = 30  // walking over poison
+)
+
+// Greedy is the reference computer player.  It looks for the move that feeds
+// a bug, preferring moves that fill a bug and set off a chain, and otherwise
+// moves bugs from long vines to short ones.  When a vine is about to
+// overflow it clears it with a row clear or a bullet if it holds one.
+//
+// Greedy only considers a single grab and spit at a time and does not plan
+// for the bugs that will spawn.
+type Greedy struct {
+	// Period is the number of ticks between controls.  A Period less than one
+	// acts on every tick.
+	Period int64
+
+	input []crunch.PlayerControl
+}
+
+// Play implements Player.
+func (p *Greedy) Play(s *crunch.Snapshot) []crunch.PlayerControl {
+	if p.Period > 1 && s.Tick%p.Period != 0 {
+		return nil
+	}
+	if s.Immobile || s.MovesLeft == 0 {
+		return nil
+	}
+	ctl, ok := p.choose(s)
+	if !ok {
+		return nil
+	}
+	p.input = append(p.input[:0], ctl)
+	return p.input
+}
+
+func (p *Greedy) choose(s *crunch.Snapshot) (crunch.PlayerControl, bool) {
+	if ctl, ok := rescue(s); ok {
+		return ctl, true
+	}
+	if s.Held == nil && s.Meter >= crunch.PukeMeterMax {
+		return crunch.PlayerPuke, true
+	}
+
+	var target int
+	if s.Held != nil {
+		col, _, _ := bestSpit(s, s.Vines, *s.Held, s.Pos)
+		if col < 0 {
+			// Nowhere to put the bug.  Puking it is better than letting a
+			// vine overflow while holding it.
+			if s.Meter >= crunch.PukeMeterMin {
+				return crunch.PlayerPuke, true
+			}
+			return 0, false
+		}
+		target = col
+	} else {
+		col, ok := bestGrab(s)
+		if !ok {
+			return 0, false
+		}
+		target = col
+	}
+	return walk(s, target)
+}
+
+// walk returns the control that takes the player toward column col and grabs
+// or spits once the player is there.
+func walk(s *crunch.Snapshot, col int) (crunch.PlayerControl, bool) {
+	switch {
+	case col < s.Pos:
+		return crunch.PlayerMoveLeft, true
+	case col > s.Pos:
+		return crunch.PlayerMoveRight, true
+	}
+	return crunch.PlayerGrabSpit, true
+}
+
+// rescue returns the control to use a row clear or bullet on a vine about to
+// overflow.
+func rescue(s *crunch.Snapshot) (crunch.PlayerControl, bool) {
+	col := -1
+	for i, vine := range s.Vines {
+		if len(vine) >= s.ColDepth-1 && (col < 0 || len(vine) > len(s.Vines[col])) {
+			col = i
+		}
+	}
+	if col < 0 {
+		return 0, false
+	}
+	k := -1
+	for i, inv := range s.Inventory {
+		if inv.Type == crunch.ItemRowClear || inv.Type == crunch.ItemBullet {
+			k = i
+			break
+		}
+	}
+	if k < 0 {
+		return 0, false
+	}
+	if k > 0 {
+		return crunch.PlayerItemForward, true
+	}
+	if col != s.Pos {
+		ctl, _ := walk(s, col)
+		return ctl, true
+	}
+	return crunch.PlayerItemUse, true
+}
+
+// bestGrab returns the column to grab from.  The bottom bug of each vine is
+// paired with the vine it is best spat onto and the pair worth the most, less
+// the walk, is chosen.  No column is returned if no move is worth making.
+func bestGrab(s *crunch.Snapshot) (int, bool) {
+	best, bestValue := -1, 0
+	vines := make([][]crunch.SnapshotBug, len(s.Vines))
+	for i, vine := range s.Vines {
+		if s.Settling[i] || len(vine) == 0 {
+			continue
+		}
+		bug := vine[len(vine)-1]
+		if bug.Exploded || bug.Eaten >= 2 {
+			continue
+		}
+		copy(vines, s.Vines)
+		vines[i] = vine[:len(vine)-1]
+		col, value, gain := bestSpit(s, vines, bug, i)
+		if col < 0 || (col == i && gain == 0) {
+			// Putting the bug back where it was achieves nothing.
+			continue
+		}
+		// Taking a bug off a long vine buys time.
+		value += len(vine) - walkCost(s, s.Pos, i)
+		if value > bestValue {
+			best, bestValue = i, value
+		}
+	}
+	return best, best >= 0
+}
+
+// bestSpit returns the column where spitting bug, carried from column from,
+// is worth the most.  The value of the column counts against the length of
+// the vine and the walk, gain is the value of the spit alone.  The column is
+// negative if bug cannot be spat anywhere.
+func bestSpit(s *crunch.Snapshot, vines [][]crunch.SnapshotBug, bug crunch.SnapshotBug, from int) (col, value, gain int) {
+	col = -1
+	for i, vine := range vines {
+		if s.Settling[i] {
+			continue
+		}
+		g, ok := spitValue(s, vines, i, bug)
+		if !ok {
+			continue
+		}
+		v := g - len(vine) - walkCost(s, from, i)
+		if col < 0 || v > value {
+			col, value, gain = i, v, g
+		}
+	}
+	return col, value, gain
+}
+
+// spitValue returns the value of spitting bug onto vine i.  The result is
+// not ok if the vine is full and would not eat the bug.
+func spitValue(s *crunch.Snapshot, vines [][]crunch.SnapshotBug, i int, bug crunch.SnapshotBug) (int, bool) {
+	vine := vines[i]
+	if len(vine) > 0 {
+		j := len(vine) - 1
+		bottom := vine[j]
+		if bottom.Eats(bug) {
+			if bottom.Eaten+1+bug.Eaten < 2 {
+				return greedyFeed, true
+			}
+			switch bottom.Type {
+			case crunch.BugBomb, crunch.BugLightning:
+				return greedyChain, true
+			}
+			return greedyChain + greedyLinked*chainSize(vines, i, j, bottom.Color), true
+		}
+	}
+	if len(vine) >= s.ColDepth {
+		return 0, false
+	}
+	return 0, true
+}
+
+// walkCost returns the cost of walking from column i to column j.
+func walkCost(s *crunch.Snapshot, i, j int) int {
+	if i > j {
+		i, j = j, i
+	}
+	cost := greedyStep * (j - i)
+	for k := i + 1; k <= j && k < len(s.Ground); k++ {
+		for _, item := range s.Ground[k] {
+			if item.IsPoison() {
+				cost += greedyPoison
+			}
+		}
+	}
+	return cost
+}
+
+// chainSize returns the number of bugs other than the one at [i, j] that a
+// chain of color c starting at [i, j] would reach.
+func chainSize(vines [][]crunch.SnapshotBug, i, j int, c crunch.Color) int {
+	seen := make(map[[2]int]bool)
+	return linked(vines, i, j, c, seen) - 1
+}
+
+func linked(vines [][]crunch.SnapshotBug, i, j int, c crunch.Color, seen map[[2]int]bool) int {
+	if i < 0 || i >= len(vines) || j < 0 || j >= len(vines[i]) {
+		return 0
+	}
+	if seen[[2]int{i, j}] {
+		return 0
+	}
+	bug := vines[i][j]
+	if bug.Exploded {
+		return 0
+	}
+	switch bug.Type {
+	case crunch.BugSmall, crunch.BugLarge, crunch.BugMultiChain:
+	default:
+		return 0
+	}
+	// Snapshots give multicolored bugs the color they currently have.
+	if bug.Color != c {
+		return 0
+	}
+	seen[[2]int{i, j}] = true
+	return 1 +
+		linked(vines, i-1, j, c, seen) +
+		linked(vines, i+1, j, c, seen) +
+		linked(vines, i, j-1, c, seen) +
+		linked(vines, i, j+1, c, seen)
+}
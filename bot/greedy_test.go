@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+func testBug(typ crunch.BugType, c int, eaten int) crunch.SnapshotBug {
+	return crunch.SnapshotBug{Type: typ, Color: crunch.ColorBug + crunch.Color(c), Eaten: eaten}
+}
+
+// testSnapshot returns a survival snapshot of a board with three vines.
+func testSnapshot(pos int, vines ...[]crunch.SnapshotBug) *crunch.Snapshot {
+	return &crunch.Snapshot{
+		NumCol:    3,
+		ColDepth:  5,
+		Vines:     vines,
+		Settling:  make([]bool, len(vines)),
+		Ground:    make([][]crunch.ItemType, len(vines)),
+		Pos:       pos,
+		MovesLeft: -1,
+	}
+}
+
+func TestGreedyPlay(t *testing.T) {
+	large := testBug(crunch.BugLarge, 2, 1)
+	small := testBug(crunch.BugSmall, 0, 0)
+	gnat := crunch.SnapshotBug{Type: crunch.BugGnat}
+	rock := crunch.SnapshotBug{Type: crunch.BugRock}
+	full := []crunch.SnapshotBug{rock, rock, rock, rock, rock}
+	for _, test := range []struct {
+		name   string
+		snap   *crunch.Snapshot
+		change func(s *crunch.Snapshot)
+		ctl    crunch.PlayerControl
+		ok     bool
+	}{
+		{"empty", testSnapshot(0, nil, nil, nil), nil, 0, false},
+		{"grab", testSnapshot(2, []crunch.SnapshotBug{large}, nil, []crunch.SnapshotBug{small}), nil, crunch.PlayerGrabSpit, true},
+		{"walk to grab", testSnapshot(0, []crunch.SnapshotBug{large}, nil, []crunch.SnapshotBug{small}), nil, crunch.PlayerMoveRight, true},
+		{"walk to spit", testSnapshot(2, []crunch.SnapshotBug{large}, nil, nil), func(s *crunch.Snapshot) {
+			s.Held = &small
+		}, crunch.PlayerMoveLeft, true},
+		{"spit", testSnapshot(0, []crunch.SnapshotBug{large}, nil, nil), func(s *crunch.Snapshot) {
+			s.Held = &small
+		}, crunch.PlayerGrabSpit, true},
+		{"feed", testSnapshot(1, nil, []crunch.SnapshotBug{small}, []crunch.SnapshotBug{gnat}), nil, crunch.PlayerMoveRight, true},
+		{"puke", testSnapshot(1, nil, nil, nil), func(s *crunch.Snapshot) {
+			s.Meter = crunch.PukeMeterMax
+		}, crunch.PlayerPuke, true},
+		{"nowhere to spit", testSnapshot(1, full, full, full), func(s *crunch.Snapshot) {
+			s.Held = &small
+			s.Meter = crunch.PukeMeterMin
+		}, crunch.PlayerPuke, true},
+		{"rescue", testSnapshot(1, nil, full[:4], nil), func(s *crunch.Snapshot) {
+			s.Inventory = []crunch.Inv{{Type: crunch.ItemBullet, Quant: 1}}
+		}, crunch.PlayerItemUse, true},
+		{"choose rescue", testSnapshot(1, nil, full[:4], nil), func(s *crunch.Snapshot) {
+			s.Inventory = []crunch.Inv{{Type: crunch.ItemScramble, Quant: 1}, {Type: crunch.ItemRowClear, Quant: 1}}
+		}, crunch.PlayerItemForward, true},
+		{"immobile", testSnapshot(2, []crunch.SnapshotBug{large}, nil, []crunch.SnapshotBug{small}), func(s *crunch.Snapshot) {
+			s.Immobile = true
+		}, 0, false},
+		{"no moves", testSnapshot(2, []crunch.SnapshotBug{large}, nil, []crunch.SnapshotBug{small}), func(s *crunch.Snapshot) {
+			s.MovesLeft = 0
+		}, 0, false},
+		{"settling", testSnapshot(2, []crunch.SnapshotBug{large}, nil, []crunch.SnapshotBug{small}), func(s *crunch.Snapshot) {
+			s.Settling[0] = true
+		}, 0, false},
+	} {
+		if test.change != nil {
+			test.change(test.snap)
+		}
+		input := (&Greedy{}).Play(test.snap)
+		if !test.ok {
+			if len(input) != 0 {
+				t.Errorf("%s: controls %v", test.name, input)
+			}
+			continue
+		}
+		if len(input) != 1 || input[0] != test.ctl {
+			t.Errorf("%s: controls %v (expected %v)", test.name, input, test.ctl)
+		}
+	}
+}
+
+func TestGreedyPeriod(t *testing.T) {
+	s := testSnapshot(0, []crunch.SnapshotBug{testBug(crunch.BugLarge, 2, 1)}, nil, []crunch.SnapshotBug{testBug(crunch.BugSmall, 0, 0)})
+	p := &Greedy{Period: GreedyPeriod}
+	for tick := int64(0); tick < 2*GreedyPeriod; tick++ {
+		s.Tick = tick
+		input := p.Play(s)
+		if (len(input) != 0) != (tick%GreedyPeriod == 0) {
+			t.Errorf("tick %d: controls %v", tick, input)
+		}
+	}
+}
+
+func TestChainSize(t *testing.T) {
+	red, blue := 0, 1
+	vines := [][]crunch.SnapshotBug{
+		{testBug(crunch.BugSmall, red, 0), testBug(crunch.BugLarge, red, 1)},
+		{testBug(crunch.BugSmall, red, 0), testBug(crunch.BugSmall, blue, 0)},
+		{{Type: crunch.BugRock}, testBug(crunch.BugSmall, blue, 0)},
+	}
+	for _, test := range []struct {
+		i, j int
+		n    int
+	}{
+		{0, 1, 2},
+		{1, 1, 1},
+		{2, 1, 1},
+	} {
+		c := vines[test.i][test.j].Color
+		if n := chainSize(vines, test.i, test.j, c); n != test.n {
+			t.Errorf("[%d, %d]: chain of %d (expected %d)", test.i, test.j, n, test.n)
+		}
+	}
+}
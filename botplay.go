@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bmatsuo/cimoj/bot"
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+// playBot makes the bot named name play g instead of the player.  The game is
+// not recorded because the player did not play it.
+func (g *CrunchGame) playBot(name string) {
+	preset := bot.Lookup(name)
+	if preset == nil {
+		return
+	}
+	g.bot = preset.New(g.engine.Seed())
+	g.scoreDB = nil
+	g.textReplay.SetText(fmt.Sprintf("Roboto: %s", preset.Name))
+}
+
+// stepBot advances the engine by dt one tick at a time, letting the bot
+// choose controls before every tick as it does in a simulation.
+func (g *CrunchGame) stepBot(dt time.Duration) {
+	g.botLag += dt
+	for g.botLag >= crunch.TickDuration {
+		g.botLag -= crunch.TickDuration
+		input := g.bot.Play(g.engine.Snapshot())
+		events := g.engine.Step(input, crunch.TickDuration)
+		g.recordInput(input)
+		g.handleEvents(events)
+	}
+}
@@ -0,0 +1,1031 @@
+package crunch
+
+import (
+	"image"
+	"log"
+)
+
+func (g *Game) randomColorBug(n int) Color {
+	return ColorBug + Color(g.rand.Intn(n))
+}
+
+func (g *Game) randomColorCond(t BugType) Color {
+	if t == BugSmall || t == BugLarge {
+		return g.bugDistn.RandColor(g.rand, t)
+	}
+	return bugColors[t][0]
+}
+
+func (g *Game) randomBugType() BugType {
+	return g.bugDistn.RandBugType(g.rand)
+}
+
+func (g *Game) randomBug() *Bug {
+	typ := g.randomBugType()
+	c := g.randomColorCond(typ)
+	return g.createBug(typ, c)
+}
+
+func (g *Game) createBug(typ BugType, c Color) *Bug {
+	b := &Bug{
+		Type:  typ,
+		Color: c,
+	}
+	b.Rune = g.assignRune(b)
+	return b
+}
+
+func (g *Game) assignRune(bug *Bug) rune {
+	switch bug.Type {
+	case BugSmall:
+		if bug.Eaten > 0 {
+			return '⊛'
+		}
+		return 'o'
+	case BugLarge:
+		if bug.Eaten > 0 {
+			return '@'
+		}
+		return 'O'
+	case BugGnat:
+		const gnats = "`'~"
+		return rune(gnats[g.rand.Intn(len(gnats))])
+	case BugBomb:
+		if bug.Eaten > 0 {
+			return '&'
+		}
+		return '8'
+	case BugLightning:
+		if bug.Eaten > 0 {
+			return 'X'
+		}
+		return 'x'
+	case BugRock:
+		return '▀'
+	case BugMultiChain:
+		return '*'
+	case BugMagic:
+		return '%'
+	}
+	return '?'
+}
+
+func (g *Game) spawnBugs() {
+	// the board state is initialized by rapidly spawning single bugs before
+	// bugs start coming in more predictable waves.
+	if g.bugSpawnInitRem > 0 {
+		log.Printf("INIT SPAWN")
+		g.bugSpawnInitRem--
+		g.spawnBugOnVine(g.rand.Intn(len(g.vines)))
+		return
+	}
+
+	log.Printf("ROW SPAWN")
+	// for now we do something simple and spawn bugs in all rows simultaneously
+	for i := range g.vines {
+		g.spawnBugOnVine(i)
+	}
+}
+
+// chooseNext chooses the bugs that will spawn next on any vine which does not
+// have one chosen.
+func (g *Game) chooseNext() {
+	if g.next == nil {
+		g.next = make([]*Bug, len(g.vines))
+	}
+	for i := range g.next {
+		if g.next[i] != nil {
+			continue
+		}
+		bug := g.randomBug()
+		if bug.Color == ColorMulti {
+			bug.RColor = g.randMultiColor()
+		}
+		g.next[i] = bug
+	}
+}
+
+// takeNext returns the bug chosen to spawn next on vine i and clears the
+// choice.
+func (g *Game) takeNext(i int) *Bug {
+	g.chooseNext()
+	bug := g.next[i]
+	g.next[i] = nil
+	return bug
+}
+
+func (g *Game) spawnBugOnVine(i int) {
+	g.pushBug(i, g.takeNext(i))
+}
+
+// pushBug adds bug to the top of vine i, shifting the bugs on the vine down.
+// A vine which has already overflowed is left as is.
+func (g *Game) pushBug(i int, bug *Bug) {
+	if len(g.vines[i]) == cap(g.vines[i]) {
+		return
+	}
+	g.vines[i] = g.vines[i][:len(g.vines[i])+1]
+	copy(g.vines[i][1:], g.vines[i][0:]) // shift bugs "down"
+	g.vines[i][0] = bug
+	if g.vines[i][0].Color == ColorMulti {
+		g.multis = append(g.multis, g.vines[i][0])
+	}
+	for k := range g.pendingExplos {
+		if g.pendingExplos[k].X == i {
+			g.pendingExplos[k].Y++
+		}
+	}
+	for k := range g.pendingMagics {
+		if g.pendingMagics[k].X == i {
+			g.pendingMagics[k].Y++
+		}
+	}
+	for k := range g.pendingChains {
+		if g.pendingChains[k].X == i {
+			g.pendingChains[k].Y++
+		}
+	}
+	for k := range g.pendingFeeds {
+		if g.pendingFeeds[k].X == i {
+			g.pendingFeeds[k].Y++
+		}
+	}
+	g.emit(Event{Type: EventBugSpawned, Pos: image.Pt(i, 0)})
+}
+
+func (g *Game) assignMultiColors() {
+	// multis is a slice, not a set, so that colors are drawn from the game's
+	// Rand in a deterministic order.
+	for _, bug := range g.multis {
+		bug.RColor = g.randMultiColor()
+	}
+}
+
+func (g *Game) randMultiColor() Color {
+	switch g.rand.Intn(3) {
+	case 0:
+		return ColorBug + 0
+	case 1:
+		return ColorBug + 1
+	}
+	return ColorBomb
+}
+
+// removeBug forgets any bookkeeping about a bug that has left the board.
+func (g *Game) removeBug(bug *Bug) {
+	for k := range g.multis {
+		if g.multis[k] == bug {
+			copy(g.multis[k:], g.multis[k+1:])
+			g.multis[len(g.multis)-1] = nil
+			g.multis = g.multis[:len(g.multis)-1]
+			return
+		}
+	}
+}
+
+func (g *Game) grabBug(i int) bool {
+	if i >= g.config.NumCol {
+		return false
+	}
+	var j int
+	for j = len(g.vines[i]) - 1; j >= 0; j-- {
+		bug := g.vines[i][j]
+		if bug.Exploded {
+			continue
+		}
+		if bug.Eaten >= 2 {
+			return false
+		}
+		g.player.contains = bug
+		break
+	}
+	if g.player.contains == nil {
+		return false
+	}
+	log.Printf("pos=[%d, %d] grab", i, j)
+	copy(g.vines[i][j:], g.vines[i][j+1:])
+	g.vines[i] = g.vines[i][:len(g.vines[i])-1]
+	g.emit(Event{Type: EventBugGrabbed, Pos: image.Pt(i, j)})
+	return true
+}
+
+// canEat returns true if pred can eat prey.  Large bugs eat small bugs and
+// small bugs eat gnats.  Bombs and lightning bugs eat anything but rocks.
+// Rocks are never eaten, and gnats, magic bugs, and multi-chain bugs eat
+// nothing.  Bugs that have exploded or that are full, with a reaction
+// pending, neither eat nor are eaten.
+func canEat(pred, prey *Bug) bool {
+	if pred.Exploded || prey.Exploded {
+		return false
+	}
+	if pred.Eaten >= 2 || prey.Eaten >= 2 {
+		return false
+	}
+	switch pred.Type {
+	case BugLarge:
+		return prey.Type == BugSmall
+	case BugSmall:
+		return prey.Type == BugGnat
+	case BugLightning, BugBomb:
+		return prey.Type != BugRock
+	}
+	return false
+}
+
+// bugEats feeds other to the bug at [i, j] if it can eat other.  The caller
+// is responsible for removing other from the vines.  When a bug eats, the bug
+// above it may eat it in turn, so a food chain is queued at [i, j-1].
+func (g *Game) bugEats(i, j int, other *Bug) bool {
+	if i >= g.config.NumCol || j < 0 || j >= len(g.vines[i]) {
+		return false
+	}
+	bottom := g.vines[i][j]
+	if !canEat(bottom, other) {
+		return false
+	}
+
+	bottom.Eaten += 1 + other.Eaten
+	log.Printf("pos=[%d, %d] bug was eaten", i, j)
+	g.removeBug(other)
+	if len(other.Items) > 0 {
+		// Items held by the smaller bug are transferred to the larger bug.
+		// Each item keeps its own despawn time.
+		for _, item := range bottom.holdItems(other.Items) {
+			log.Printf("pos=[%d, %d] type=%v item lost while eating", i, j, item.Type)
+			g.emit(Event{Type: EventItemDespawned, Pos: image.Pt(i, j), Item: item.Type})
+		}
+		other.Items = nil
+	}
+	bottom.Rune = g.assignRune(bottom)
+	g.emit(Event{Type: EventBugFed, Pos: image.Pt(i, j)})
+
+	if bottom.Eaten >= 2 {
+		if bottom.Type == BugBomb || bottom.Type == BugLightning {
+			g.pendingExplos = append(g.pendingExplos, image.Pt(i, j))
+		} else {
+			g.pendingChains = append(g.pendingChains, image.Pt(i, j))
+		}
+	}
+
+	// The top bug of a vine has nothing above it to continue the food chain.
+	if j > 0 {
+		g.pendingFeeds = append(g.pendingFeeds, image.Pt(i, j-1))
+	}
+
+	return true
+}
+
+// feed resolves the food chains pending on the board.  Each pending position
+// holds a bug which may eat the bug directly below it.  Food chains that
+// follow, including the eater's next meal climbing up to it, are left pending
+// for the next stage.
+func (g *Game) feed() {
+	feeds := g.pendingFeeds
+	g.pendingFeeds = nil
+	for len(feeds) > 0 {
+		i, j := feeds[0].X, feeds[0].Y
+		feeds = feeds[1:]
+		if j < 0 || j+1 >= len(g.vines[i]) {
+			continue
+		}
+		prey := g.vines[i][j+1]
+		if !g.bugEats(i, j, prey) {
+			continue
+		}
+		log.Printf("pos=[%d, %d] food chain", i, j)
+		g.removeFromVine(i, j+1)
+		decreasePtY(&feeds, i, j+1)
+		// The bug below the one eaten climbs up to the eater, which may eat
+		// it as well.
+		if j+1 < len(g.vines[i]) {
+			g.pendingFeeds = append(g.pendingFeeds, image.Pt(i, j))
+		}
+	}
+}
+
+// removeFromVine takes the bug at [i, j] off its vine, closing the space it
+// leaves, and updates any pending positions below it.
+func (g *Game) removeFromVine(i, j int) {
+	copy(g.vines[i][j:], g.vines[i][j+1:])
+	g.vines[i][len(g.vines[i])-1] = nil
+	g.vines[i] = g.vines[i][:len(g.vines[i])-1]
+	g.shiftPending(i, j)
+}
+
+// shiftPending updates pending positions in column i after the bug at depth j
+// has been removed.  Positions of the removed bug are dropped.
+func (g *Game) shiftPending(i, j int) {
+	decreasePtY(&g.pendingExplos, i, j)
+	decreasePtY(&g.pendingMagics, i, j)
+	decreasePtY(&g.pendingChains, i, j)
+	decreasePtY(&g.pendingFeeds, i, j)
+}
+
+// feedAll queues a food chain at every pair of adjacent bugs on the board.
+func (g *Game) feedAll() {
+	for i := range g.vines {
+		for j := len(g.vines[i]) - 2; j >= 0; j-- {
+			g.pendingFeeds = append(g.pendingFeeds, image.Pt(i, j))
+		}
+	}
+}
+
+func (g *Game) explode(i, j int) {
+	g.vines[i][j].Exploded = true
+	g.chainSize++
+	g.player.fillMeter(1)
+	g.chainEnd = image.Pt(i, j)
+	g.emit(Event{Type: EventBugExploded, Pos: image.Pt(i, j)})
+}
+
+// triggerExplosions resolves the reactions pending on the board.  Bomb
+// explosions and color chains end separately so that each counts toward the
+// current combo.
+func (g *Game) triggerExplosions() {
+	// Items com first because they trigger explosions and never need to be
+	// revisited with a goto/loop.
+	for i := range g.pendingItems {
+		g.fireItem(g.pendingItems[i].Type, g.pendingItems[i].Col)
+	}
+	g.pendingItems = g.pendingItems[:0]
+
+	// Bombs take precedence over other reactions which propogate more
+	// slowly, IIRC.
+	for _, pt := range g.pendingExplos {
+		g.bombChain(pt.X, pt.Y)
+	}
+	g.pendingExplos = g.pendingExplos[:0]
+
+	// TODO:
+	// Bombs drop some kind of bomb money.  I'm not sure about the
+	// rules behind that.
+
+	g.endChain()
+
+domagics:
+	for _, pt := range g.pendingMagics {
+		i, j := pt.X, pt.Y
+		if g.vines[i][j].Exploded {
+			continue
+		}
+		log.Printf("pos=[%d, %d] magic exploded color=%v", i, j, g.vines[i][j].EColor)
+		mcolor := g.vines[i][j].EColor
+		g.explode(i, j)
+		g.score++
+
+		for i := range g.vines {
+			for j := range g.vines[i] {
+				if g.vines[i][j].Color == mcolor {
+					log.Printf("pos=[%d, %d] exploaded by magic at pos=[%d, %d]", i, j, pt.X, pt.Y)
+					g.vines[i][j].Exploded = true
+					g.player.fillMeter(1)
+					g.emit(Event{Type: EventBugExploded, Pos: image.Pt(i, j)})
+					g.score++
+				}
+			}
+		}
+	}
+	g.pendingMagics = g.pendingMagics[:0]
+
+	// Magic cannot trigger bombs because bombs just destroy magic.  So we just
+	// move on to pending chains.
+
+	for _, pt := range g.pendingChains {
+		i, j := pt.X, pt.Y
+		if j >= len(g.vines[i]) {
+			log.Printf("pos=[%d, %d] pending chain is off the vine", i, j)
+			continue
+		}
+		g.colorChain(i, j, g.vines[i][j].Color)
+	}
+	g.pendingChains = g.pendingChains[:0]
+
+	// Bombs may be triggered by chains (multichain I think?). But, in
+	// order to take precedence over other chaining bombs should
+	// explode while the chain is resolving...  But there is currently
+	// a problem with the order of chain resolution not respecting
+	// physics.  Dammit.
+	if len(g.pendingMagics) > 0 {
+		goto domagics
+	}
+
+	g.endChain()
+}
+
+// endChain drops money for any chain that has been triggered and resets the
+// chain counter.
+func (g *Game) endChain() {
+	if g.chainSize > 0 {
+		typ := g.moneySize()
+		g.emit(Event{Type: EventChain, Pos: g.chainEnd, Item: typ, Value: int64(g.chainSize)})
+		g.continueCombo()
+		g.dropItem(g.chainEnd, typ)
+		g.chainSize = 0
+	}
+}
+
+// dropItem drops a new item of type typ from the bug at pt.
+func (g *Game) dropItem(pt image.Point, typ ItemType) {
+	g.dropHeldItem(pt, &Item{
+		Type:    typ,
+		Despawn: g.now.Add(GroundItemTime),
+	})
+}
+
+// dropHeldItem drops an item held by the bug at pt.  The item keeps its
+// despawn time on the ground.
+func (g *Game) dropHeldItem(pt image.Point, item *Item) {
+	log.Printf("pos=[%d, %d] item=%v a bug dropped an item", pt.X, pt.Y, item.Type)
+	g.emit(Event{Type: EventItemDropped, Pos: image.Pt(pt.X, 0), Item: item.Type})
+
+	if g.player.pos == pt.X {
+		g.acquireItem(item.Type)
+		return
+	}
+
+	g.ground.insertItem(g.now, pt.X, item)
+}
+
+// dropPoison drops poison from the bug at pt with probability rate.
+func (g *Game) dropPoison(pt image.Point, rate float64) {
+	if rate <= 0 || g.rand.Float64() >= rate {
+		return
+	}
+	g.dropItem(pt, ItemPoison)
+}
+
+func (g *Game) fireItem(typ ItemType, i int) {
+	switch typ {
+	case ItemRowClear:
+		g.fireItemRowClear(i)
+	case ItemPushUp:
+		g.fireItemPushUp(i)
+	case ItemBullet:
+		g.fireItemBullet(i)
+	case ItemScramble:
+		g.fireItemScramble(i)
+	case ItemRecolor:
+		g.fireItemRecolor(i)
+	}
+}
+
+func (g *Game) fireItemRowClear(i int) {
+	j := len(g.vines[i]) - 1
+	if j < 0 {
+		return
+	}
+
+	for i := range g.vines {
+		if len(g.vines[i]) <= j {
+			continue
+		}
+
+		if g.vines[i][j].Type == BugBomb || g.vines[i][j].Type == BugLightning {
+			g.pendingExplos = append(g.pendingExplos, image.Pt(i, j))
+			continue
+		}
+
+		g.explode(i, j)
+	}
+}
+
+func (g *Game) fireItemPushUp(i int) {
+	for i := range g.vines {
+		if len(g.vines[i]) == 0 {
+			continue
+		}
+
+		g.removeBug(g.vines[i][0])
+		copy(g.vines[i], g.vines[i][1:])
+		if len(g.vines[i]) > 1 {
+			g.vines[i][len(g.vines[i])-1] = nil
+		}
+		g.vines[i] = g.vines[i][:len(g.vines[i])-1]
+
+		g.shiftPending(i, 0)
+	}
+}
+
+func (g *Game) fireItemBullet(i int) {
+	j := len(g.vines[i]) - 1
+	if j < 0 {
+		return
+	}
+
+	if g.vines[i][j].Type == BugBomb || g.vines[i][j].Type == BugLightning {
+		g.pendingExplos = append(g.pendingExplos, image.Pt(i, j))
+		return
+	}
+
+	g.explode(i, j)
+}
+
+func (g *Game) fireItemScramble(i int) {
+	// Reactions pending on scrambled bugs follow the bugs to their new
+	// positions.
+	explos := g.bugsAt(g.pendingExplos)
+	chains := g.bugsAt(g.pendingChains)
+
+	for i := range g.vines {
+		for j := range g.vines[i] {
+			g.bugBuffer = append(g.bugBuffer, g.vines[i][j])
+			g.vines[i][j] = nil
+		}
+		g.vines[i] = g.vines[i][:0]
+	}
+
+	for k := range g.bugBuffer {
+		i := k % len(g.vines)
+		g.vines[i] = append(g.vines[i], g.bugBuffer[k])
+	}
+
+	g.clearBugBuffer()
+	g.pendingExplos = g.locateBugs(g.pendingExplos[:0], explos)
+	g.pendingChains = g.locateBugs(g.pendingChains[:0], chains)
+
+	// Every bug has new neighbors which it may eat.
+	g.pendingFeeds = g.pendingFeeds[:0]
+	g.feedAll()
+}
+
+// bugsAt returns the bugs at the given positions.  Positions off the vines
+// are ignored.
+func (g *Game) bugsAt(pts []image.Point) []*Bug {
+	var bugs []*Bug
+	for _, pt := range pts {
+		if pt.X < len(g.vines) && pt.Y < len(g.vines[pt.X]) {
+			bugs = append(bugs, g.vines[pt.X][pt.Y])
+		}
+	}
+	return bugs
+}
+
+// locateBugs appends the positions of bugs to pts.
+func (g *Game) locateBugs(pts []image.Point, bugs []*Bug) []image.Point {
+	for _, bug := range bugs {
+		for i := range g.vines {
+			for j := range g.vines[i] {
+				if g.vines[i][j] == bug {
+					pts = append(pts, image.Pt(i, j))
+				}
+			}
+		}
+	}
+	return pts
+}
+
+func (g *Game) clearBugBuffer() {
+	for i := range g.bugBuffer {
+		g.bugBuffer[i] = nil
+	}
+	g.bugBuffer = g.bugBuffer[:0]
+}
+
+func (g *Game) fireItemRecolor(i int) {
+	for i := range g.vines {
+		for j := range g.vines[i] {
+			switch g.vines[i][j].Color {
+			case ColorBug + 1, ColorBug + 3:
+				g.vines[i][j].Color--
+			}
+		}
+	}
+}
+
+func (g *Game) pickUpItems(i int) {
+	items := g.ground.takeItems(g.now, i)
+	for i := range items {
+		g.acquireItem(items[i].Type)
+	}
+}
+
+func (g *Game) acquireItem(typ ItemType) {
+	pointsRaw := g.pointValue(typ)
+	var points int64
+	if pointsRaw > 0 {
+		points = int64(float64(pointsRaw) * g.Multiplier())
+		log.Printf("points=%d raw=%d adjusted point value", points, pointsRaw)
+		g.score += points
+	}
+	if typ.IsPoison() {
+		points = -g.poisonPlayer()
+	}
+	if typ.IsSpecial() {
+		log.Printf("type=%v special item acquired", typ)
+		g.player.addInv(typ)
+	}
+	g.emit(Event{Type: EventItemAcquired, Pos: image.Pt(g.player.pos, 0), Item: typ, Value: points})
+}
+
+// poisonPlayer applies the penalty for picking up poison and returns the
+// number of points lost.  The combo is shortened rather than the score
+// multiplier scaled directly, so the penalty holds when the combo continues.
+func (g *Game) poisonPlayer() int64 {
+	rules := g.poison
+	lost := rules.Score
+	if lost > g.score {
+		lost = g.score
+	}
+	g.score -= lost
+	if g.combo > 1 {
+		g.combo = 1 + int(float64(g.combo-1)*rules.Multiplier)
+		g.scoreMultiplier = comboMultiplier(g.combo)
+	}
+	g.feedMultiplier = 1 + (g.feedMultiplier-1)*rules.Multiplier
+	g.player.poisoned = g.now.Add(rules.Immobilize)
+	if g.player.poisoned.After(g.player.immobilized) {
+		g.player.immobilized = g.player.poisoned
+	}
+	log.Printf("lost=%d immobilized=%v poisoned", lost, rules.Immobilize)
+	return lost
+}
+
+func (g *Game) pointValue(typ ItemType) int {
+	switch typ {
+	case ItemMoneyXXS:
+		return 10
+	case ItemMoneyXS:
+		return 20
+	case ItemMoneySM:
+		return 40
+	case ItemMoneyMD:
+		return 80
+	case ItemMoneyLG:
+		return 160
+	case ItemMoneyXL:
+		return 320
+	case ItemMoneyXXL:
+		return 640
+	}
+	return 0
+}
+
+// moneySize is called when a piece of money is generated and is based
+// on the size of the chain that caused it.
+func (g *Game) moneySize() ItemType {
+	if g.chainSize < 3 {
+		return ItemMoneyXXS
+	}
+	if g.chainSize < 5 {
+		return ItemMoneyXS
+	}
+	if g.chainSize < 8 {
+		return ItemMoneySM
+	}
+	if g.chainSize < 12 {
+		return ItemMoneyMD
+	}
+	if g.chainSize < 17 {
+		return ItemMoneyLG
+	}
+	if g.chainSize < 21 {
+		return ItemMoneyXL
+	}
+	return ItemMoneyXXL
+}
+
+// moneyPoints is called when the player picks up a piece of money.
+func (g *Game) moneyPoints(ItemType) int {
+	return 0
+}
+
+// hasExploded returns true if any bug on the vines has exploded.
+func (g *Game) hasExploded() bool {
+	for i := range g.vines {
+		for j := range g.vines[i] {
+			if g.vines[i][j].Exploded {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// settling returns true while a reaction on the board is unresolved.
+func (g *Game) settling() bool {
+	if len(g.pendingItems) > 0 || len(g.pendingExplos) > 0 || len(g.pendingChains) > 0 || len(g.pendingFeeds) > 0 {
+		return true
+	}
+	return g.hasExploded()
+}
+
+// columnSettling returns true while a reaction in column i is unresolved.
+func (g *Game) columnSettling(i int) bool {
+	if i < 0 || i >= len(g.vines) {
+		return false
+	}
+	for j := range g.vines[i] {
+		if g.vines[i][j].Exploded {
+			return true
+		}
+	}
+	for _, pt := range g.pendingExplos {
+		if pt.X == i {
+			return true
+		}
+	}
+	for _, pt := range g.pendingChains {
+		if pt.X == i {
+			return true
+		}
+	}
+	for _, pt := range g.pendingFeeds {
+		if pt.X == i {
+			return true
+		}
+	}
+	return false
+}
+
+func decreasePtY(pts *[]image.Point, x, min int) {
+	next := 0
+	for k := range *pts {
+		if (*pts)[k].X != x {
+			(*pts)[next] = (*pts)[k]
+			next++
+			continue
+		}
+		if (*pts)[k].Y < min {
+			(*pts)[next] = (*pts)[k]
+			next++
+			continue
+		}
+		if (*pts)[k].Y == min {
+			continue
+		}
+		(*pts)[next] = (*pts)[k]
+		(*pts)[next].Y--
+		next++
+	}
+	*pts = (*pts)[:next]
+}
+
+// clearExploded removes exploded bugs from the vines and closes the gaps they
+// leave.  Bugs below a gap climb up to close it, except that a bug at the
+// bottom of a vine which does not climb falls to the ground.  The bug above a
+// closed gap may eat the bug that climbed up to it, so a food chain is queued
+// there.
+func (g *Game) clearExploded() {
+	for i := range g.vines {
+		vine := g.vines[i]
+		// Bugs are kept by moving them to the front of the vine.  The n
+		// bugs kept so far are the bugs above any pending position being
+		// updated.
+		n := 0
+		gap := false
+		for j, bug := range vine {
+			if bug.Exploded {
+				gap = true
+				for _, item := range bug.Items {
+					g.dropHeldItem(image.Pt(i, j), item)
+				}
+				if bug.Type == BugGnat {
+					g.dropPoison(image.Pt(i, j), g.poison.GnatRate)
+				}
+				g.shiftPending(i, n)
+				g.removeBug(bug)
+				continue
+			}
+			if gap && j == len(vine)-1 && !bugClimbs(bug.Type) {
+				log.Printf("pos=[%d, %d] dropped from the vines", i, j)
+				g.shiftPending(i, n)
+				g.removeBug(bug)
+				g.emit(Event{Type: EventBugDropped, Pos: image.Pt(i, j)})
+				if bug.Type == BugRock {
+					g.dropPoison(image.Pt(i, j), g.poison.RockRate)
+				}
+				g.landBug(i, bug)
+				continue
+			}
+			if gap && n > 0 {
+				g.pendingFeeds = append(g.pendingFeeds, image.Pt(i, n-1))
+			}
+			gap = false
+			vine[n] = bug
+			n++
+		}
+		if n < len(vine) {
+			for j := n; j < len(vine); j++ {
+				vine[j] = nil
+			}
+			g.vines[i] = vine[:n]
+			log.Printf("col=%d compacted remaining=%d", i, n)
+		}
+	}
+}
+
+// bugDetonates returns true if bugs of type t detonate when they land on the
+// ground.
+func bugDetonates(t BugType) bool {
+	return t == BugBomb || t == BugLightning
+}
+
+// blastRadius returns the number of columns on either side of a bug of type
+// t caught in its blast on the ground.
+func blastRadius(t BugType) int {
+	if t == BugLightning {
+		return 2
+	}
+	return 1
+}
+
+// landBug puts a bug that fell off vine i on the ground beneath it.  A bug
+// that lands on the player hits them, and a bomb or lightning bug that lands
+// on the player detonates immediately.
+func (g *Game) landBug(i int, bug *Bug) {
+	log.Printf("col=%d type=%v bug landed", i, bug.Type)
+	g.emit(Event{Type: EventBugLanded, Pos: image.Pt(i, 0)})
+	if bugDetonates(bug.Type) {
+		if g.player.pos == i {
+			g.groundBlast(i, bug)
+			return
+		}
+		g.ground.land(i, bug, g.now.Add(GroundFuseTime))
+		return
+	}
+	if g.player.pos == i {
+		g.hitPlayer(false)
+	}
+	g.ground.land(i, bug, g.now.Add(LandedRestTime))
+}
+
+// checkLanded detonates bugs on the ground whose fuse has run out and clears
+// away the others once they have rested.
+func (g *Game) checkLanded() {
+	for i := range g.vines {
+		for _, landed := range g.ground.takeLanded(g.now, i, false) {
+			if bugDetonates(landed.Bug.Type) {
+				g.groundBlast(i, landed.Bug)
+			}
+		}
+	}
+}
+
+// groundBlast detonates bug on the ground beneath column i.  Items and other
+// landed bugs in the blast are destroyed, though bombs and lightning bugs in
+// the blast detonate as well.
+func (g *Game) groundBlast(i int, bug *Bug) {
+	r := blastRadius(bug.Type)
+	log.Printf("col=%d type=%v radius=%d ground blast", i, bug.Type, r)
+	g.emit(Event{Type: EventGroundBlast, Pos: image.Pt(i, 0), Value: int64(r)})
+	for ik := i - r; ik <= i+r; ik++ {
+		if ik < 0 || ik >= len(g.vines) {
+			continue
+		}
+		g.ground.clearItems(ik)
+		for _, landed := range g.ground.takeLanded(g.now, ik, true) {
+			if bugDetonates(landed.Bug.Type) {
+				g.groundBlast(ik, landed.Bug)
+			}
+		}
+	}
+	if g.player.pos >= i-r && g.player.pos <= i+r {
+		g.hitPlayer(true)
+	}
+}
+
+// hitPlayer applies the penalty for being hit by a falling bug or, if blast
+// is true, for being caught in a blast.
+func (g *Game) hitPlayer(blast bool) {
+	rules := g.hazard
+	lost := rules.Damage
+	if lost > g.score {
+		lost = g.score
+	}
+	g.score -= lost
+	g.player.stunned = g.now.Add(rules.Stun)
+	if g.player.stunned.After(g.player.immobilized) {
+		g.player.immobilized = g.player.stunned
+	}
+	if blast && rules.Lethal {
+		log.Printf("player killed by a blast")
+		g.killed = true
+	}
+	log.Printf("lost=%d stunned=%v blast=%v player hit", lost, rules.Stun, blast)
+	g.emit(Event{Type: EventPlayerHit, Pos: image.Pt(g.player.pos, 0), Value: lost})
+}
+
+func (g *Game) bombChain(i, j int) {
+	if i < 0 {
+		return
+	}
+	if i >= len(g.vines) {
+		return
+	}
+	if j < 0 {
+		return
+	}
+	if j >= len(g.vines[i]) {
+		return
+	}
+	if g.vines[i][j].Exploded {
+		return
+	}
+
+	g.explode(i, j)
+	//g.score++
+
+	log.Printf("pos=[%d, %d] exploded by bomb", i, j)
+	if g.vines[i][j].Type == BugBomb {
+		log.Printf("pos=[%d, %d] bomb exploded", i, j)
+		// Explode nearby bugs; out of bounds accesses are handled in the call.
+		// The following nested loop will call g.colorChain(i, j) again but
+		// we should have already exploded index (i,j) and no infinite
+		// recursion will occur.
+		for ik := i - 1; ik <= i+1; ik++ {
+			for jk := j - 1; jk <= j+1; jk++ {
+				g.bombChain(ik, jk)
+				g.bombChain(ik, jk)
+			}
+		}
+	} else if g.vines[i][j].Type == BugLightning {
+		g.bombChain(i+1, j+1)
+		g.bombChain(i+1, j-1)
+		g.bombChain(i-1, j+1)
+		g.bombChain(i-1, j-1)
+		g.bombChain(i+2, j+2)
+		g.bombChain(i+2, j-2)
+		g.bombChain(i-2, j+2)
+		g.bombChain(i-2, j-2)
+	}
+}
+
+func (g *Game) colorChain(i, j int, c Color) {
+	if i < 0 {
+		return
+	}
+	if i >= len(g.vines) {
+		return
+	}
+	if j < 0 {
+		return
+	}
+	if j >= len(g.vines[i]) {
+		return
+	}
+	if g.vines[i][j].Exploded {
+		return
+	}
+	if g.vines[i][j].Type != BugSmall && g.vines[i][j].Type != BugLarge && g.vines[i][j].Type != BugMultiChain {
+		if g.vines[i][j].Type == BugMagic && g.vines[i][j].EColor == ColorNone && c != ColorMulti {
+			log.Printf("pos=[%d, %d] magic triggered", i, j)
+			g.vines[i][j].EColor = c
+			g.pendingMagics = append(g.pendingMagics, image.Pt(i, j))
+		}
+		return
+	}
+
+	// Check the input color and adjust the color for recursive calls if
+	// necessary.
+	if g.vines[i][j].Color == ColorMulti {
+		c = ColorMulti
+	} else if c == ColorMulti {
+		c = g.vines[i][j].Color
+	} else if g.vines[i][j].Color != c {
+		return
+	}
+
+	log.Printf("pos=[%d, %d] exploaded in chain color=%v", i, j, c)
+	g.explode(i, j)
+	//g.score++
+
+	if i > 0 {
+		g.colorChain(i-1, j, c)
+	}
+	if i < len(g.vines)-1 {
+		g.colorChain(i+1, j, c)
+	}
+
+	if j > 0 {
+		g.colorChain(i, j-1, c)
+	}
+	if j < len(g.vines[i])-1 {
+		g.colorChain(i, j+1, c)
+	}
+}
+
+func (g *Game) spitBug(i int) bool {
+	if i >= g.config.NumCol {
+		return false
+	}
+
+	spat := g.player.contains
+	g.player.contains = nil
+
+	if g.bugEats(i, len(g.vines[i])-1, spat) {
+		return true
+	}
+
+	if len(g.vines[i]) >= g.config.ColDepth {
+		log.Printf("col=%d cannot spit", i)
+		g.player.contains = spat
+		return false
+	}
+
+	g.vines[i] = g.vines[i][:len(g.vines[i])+1]
+	g.vines[i][len(g.vines[i])-1] = spat
+	log.Printf("pos=[%d, %d] spit", i, len(g.vines[i])-1)
+	g.emit(Event{Type: EventBugSpat, Pos: image.Pt(i, len(g.vines[i])-1)})
+
+	return true
+}
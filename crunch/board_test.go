@@ -0,0 +1,289 @@
+package crunch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExplodedBugDropsItems(t *testing.T) {
+	bug := testBug(BugSmall, 0)
+	bug.Exploded = true
+	bug.Items = []*Item{
+		{Type: ItemBullet},
+		{Type: ItemScramble, Despawn: time.Time{}.Add(time.Minute)},
+	}
+	g := newTestBoard(t, []*Bug{bug})
+	events := g.Step(nil, TickDuration)
+	if n := countEvents(events, EventItemDropped); n != 2 {
+		t.Fatalf("%d items dropped", n)
+	}
+	slot := g.Ground().Slot(0)
+	if len(slot) != 2 || slot[0].Type != ItemBullet || slot[1].Type != ItemScramble {
+		t.Fatalf("ground holds %v", slot)
+	}
+
+	// Each item keeps its own despawn time on the ground.
+	g.Step(nil, time.Minute)
+	slot = g.Ground().Slot(0)
+	if len(slot) != 1 || slot[0].Type != ItemBullet {
+		t.Fatalf("ground holds %v after a minute", slot)
+	}
+}
+
+// fallingBug returns a vine whose bottom bug is about to fall because the bug
+// above it has exploded.
+func fallingBug(typ BugType) []*Bug {
+	above := testBug(BugSmall, 0)
+	above.Exploded = true
+	return []*Bug{above, testBug(typ, 0)}
+}
+
+// putTestItems puts an item that never despawns beneath each column.
+func putTestItems(g *Game) {
+	for i := range g.vines {
+		g.ground.insertItem(g.now, i, &Item{Type: ItemBullet})
+	}
+}
+
+func TestBombLandsOnPlayer(t *testing.T) {
+	g := newTestBoard(t, fallingBug(BugBomb))
+	putTestItems(g)
+	g.player.pos = 0
+	g.score = 100
+	events := g.Step(nil, TickDuration)
+	for _, typ := range []EventType{EventBugDropped, EventBugLanded, EventGroundBlast, EventPlayerHit} {
+		if n := countEvents(events, typ); n != 1 {
+			t.Fatalf("%d %v events", n, typ)
+		}
+	}
+	if len(g.Vine(0)) != 0 || len(g.Ground().Landed(0)) != 0 {
+		t.Fatal("the bomb did not detonate")
+	}
+	for i, n := range []int{0, 0, 1, 1} {
+		if len(g.Ground().Slot(i)) != n {
+			t.Fatalf("ground beneath column %d holds %d items (expected %d)", i, len(g.Ground().Slot(i)), n)
+		}
+	}
+	if !g.Stunned() || g.Score() != 100-g.hazard.Damage || g.Over() {
+		t.Fatalf("stunned %v, score %d, over %v", g.Stunned(), g.Score(), g.Over())
+	}
+}
+
+func TestBombFuse(t *testing.T) {
+	g := newTestBoard(t, nil, nil, fallingBug(BugBomb))
+	putTestItems(g)
+	g.player.pos = 5
+	events := g.Step(nil, TickDuration)
+	if countEvents(events, EventBugLanded) != 1 || len(g.Ground().Landed(2)) != 1 {
+		t.Fatal("the bomb did not land")
+	}
+	events = g.Step(nil, GroundFuseTime-TickDuration)
+	if countEvents(events, EventGroundBlast) != 0 {
+		t.Fatal("the bomb detonated before its fuse ran out")
+	}
+	events = g.Step(nil, TickDuration)
+	if countEvents(events, EventGroundBlast) != 1 || countEvents(events, EventPlayerHit) != 0 {
+		t.Fatalf("events %v", events)
+	}
+	for i, n := range []int{1, 0, 0, 0, 1} {
+		if len(g.Ground().Slot(i)) != n {
+			t.Fatalf("ground beneath column %d holds %d items (expected %d)", i, len(g.Ground().Slot(i)), n)
+		}
+	}
+}
+
+func TestRockLands(t *testing.T) {
+	g := newTestBoard(t, fallingBug(BugRock))
+	g.poison.RockRate = 0
+	putTestItems(g)
+	g.player.pos = 1
+	g.score = 100
+	events := g.Step(nil, TickDuration)
+	if countEvents(events, EventBugLanded) != 1 || len(g.Ground().Landed(0)) != 1 {
+		t.Fatal("the rock did not land")
+	}
+	harm := countEvents(events, EventGroundBlast) + countEvents(events, EventPlayerHit)
+	events = g.Step(nil, LandedRestTime)
+	harm += countEvents(events, EventGroundBlast) + countEvents(events, EventPlayerHit)
+	if len(g.Ground().Landed(0)) != 0 {
+		t.Fatal("the rock remains on the ground")
+	}
+	if harm != 0 {
+		t.Fatalf("%d blasts and hits", harm)
+	}
+	if len(g.Ground().Slot(0)) != 1 || g.Score() != 100 || g.Stunned() {
+		t.Fatal("the rock did harm")
+	}
+}
+
+func TestFoodChains(t *testing.T) {
+	exploded := func() *Bug {
+		bug := testBug(BugSmall, 1)
+		bug.Exploded = true
+		return bug
+	}
+	fed := func(bug *Bug, n int8) *Bug {
+		bug.Eaten = n
+		return bug
+	}
+	type result struct {
+		typ   BugType
+		eaten int8
+	}
+	for _, test := range []struct {
+		name string
+		vine []*Bug
+		spit *Bug
+		want []result
+	}{
+		{"prey below a gap", []*Bug{testBug(BugLarge, 2), exploded(), testBug(BugSmall, 0)}, nil,
+			[]result{{BugLarge, 1}}},
+		{"prey above a gap", []*Bug{testBug(BugSmall, 0), exploded(), testBug(BugLarge, 2)}, nil,
+			[]result{{BugSmall, 0}, {BugLarge, 0}}},
+		{"upward chain", []*Bug{testBug(BugSmall, 0), testBug(BugLarge, 2), testBug(BugSmall, 0)}, &Bug{Type: BugGnat},
+			[]result{{BugSmall, 0}}},
+		{"bomb on top", []*Bug{fed(testBug(BugBomb, 0), 1)}, testBug(BugSmall, 0),
+			nil},
+		{"bomb above a chain", []*Bug{testBug(BugBomb, 0), testBug(BugSmall, 0)}, &Bug{Type: BugGnat},
+			nil},
+		{"bomb above a rock", []*Bug{testBug(BugBomb, 0)}, &Bug{Type: BugRock},
+			[]result{{BugBomb, 0}, {BugRock, 0}}},
+		{"rock", []*Bug{{Type: BugRock}}, testBug(BugSmall, 0),
+			[]result{{BugRock, 0}, {BugSmall, 0}}},
+		{"gnat eaten by a small bug", []*Bug{testBug(BugSmall, 0)}, &Bug{Type: BugGnat},
+			[]result{{BugSmall, 1}}},
+		{"gnat eaten by a bomb", []*Bug{testBug(BugBomb, 0)}, &Bug{Type: BugGnat},
+			[]result{{BugBomb, 1}}},
+		{"gnat under a large bug", []*Bug{testBug(BugLarge, 2)}, &Bug{Type: BugGnat},
+			[]result{{BugLarge, 0}, {BugGnat, 0}}},
+		{"gnat eats nothing", []*Bug{{Type: BugGnat}}, &Bug{Type: BugGnat},
+			[]result{{BugGnat, 0}, {BugGnat, 0}}},
+	} {
+		// Column 5 is where a bomb once food chained itself and never
+		// exploded.
+		const col = 5
+		vines := make([][]*Bug, col+1)
+		vines[col] = test.vine
+		g := newTestBoard(t, vines...)
+		g.player.pos = col
+		g.Step(nil, TickDuration)
+		if test.spit != nil {
+			g.player.contains = test.spit
+			g.Step([]PlayerControl{PlayerGrabSpit}, 0)
+			g.Step(nil, TickDuration)
+		}
+		if g.Settling() {
+			t.Errorf("%s: the column is still settling", test.name)
+			continue
+		}
+		var got []result
+		for _, bug := range g.Vine(col) {
+			got = append(got, result{bug.Type, bug.Eaten})
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: column %v (expected %v)", test.name, got, test.want)
+			continue
+		}
+		for j := range got {
+			if got[j] != test.want[j] {
+				t.Errorf("%s: column %v (expected %v)", test.name, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCanEat(t *testing.T) {
+	types := []BugType{BugSmall, BugLarge, BugGnat, BugMagic, BugBomb, BugLightning, BugRock, BugMultiChain}
+	eats := map[BugType][]BugType{
+		BugSmall:     {BugGnat},
+		BugLarge:     {BugSmall},
+		BugBomb:      {BugSmall, BugLarge, BugGnat, BugMagic, BugBomb, BugLightning, BugMultiChain},
+		BugLightning: {BugSmall, BugLarge, BugGnat, BugMagic, BugBomb, BugLightning, BugMultiChain},
+	}
+	for _, pred := range types {
+		for _, prey := range types {
+			var want bool
+			for _, typ := range eats[pred] {
+				want = want || typ == prey
+			}
+			if canEat(&Bug{Type: pred}, &Bug{Type: prey}) != want {
+				t.Errorf("%v eats %v: %v", pred, prey, !want)
+			}
+			if canEat(&Bug{Type: pred, Eaten: 2}, &Bug{Type: prey}) || canEat(&Bug{Type: pred}, &Bug{Type: prey, Exploded: true}) {
+				t.Errorf("%v eats %v when full or exploded", pred, prey)
+			}
+		}
+	}
+}
+
+func TestPoisonDrops(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		vine []*Bug
+		rate func(r *PoisonRules) *float64
+	}{
+		{"rock", fallingBug(BugRock), func(r *PoisonRules) *float64 { return &r.RockRate }},
+		{"gnat", []*Bug{{Type: BugGnat, Exploded: true}}, func(r *PoisonRules) *float64 { return &r.GnatRate }},
+	} {
+		for _, rate := range []float64{0, 0.5, 1} {
+			var dropped int
+			const n = 200
+			for k := 0; k < n; k++ {
+				vine := make([]*Bug, len(test.vine))
+				for j, bug := range test.vine {
+					copied := *bug
+					vine[j] = &copied
+				}
+				g := newTestBoard(t, vine)
+				g.rand = newCountingRand(int64(k))
+				g.poison = PoisonRules{}
+				*test.rate(&g.poison) = rate
+				g.Step(nil, TickDuration)
+				for _, item := range g.Ground().Slot(0) {
+					if item.Type == ItemPoison {
+						dropped++
+					}
+				}
+			}
+			if dropped < int(rate*n*0.8) || dropped > int(rate*n*1.2) {
+				t.Errorf("%s: poison dropped %d of %d times at rate %v", test.name, dropped, n, rate)
+			}
+		}
+	}
+}
+
+func TestPoisonPickup(t *testing.T) {
+	g := newTestBoard(t)
+	g.player.pos = 0
+	g.Step(nil, TickDuration)
+	g.score = 100
+	g.combo = 5
+	g.scoreMultiplier = comboMultiplier(g.combo)
+	g.comboExpires = g.now.Add(ComboTime)
+	g.feedMultiplier = 2
+	g.feedExpires = g.now.Add(PukeBoostTime)
+	g.ground.insertItem(g.now, 1, &Item{Type: ItemPoison})
+
+	events := g.Step([]PlayerControl{PlayerMoveRight}, 0)
+	if len(events) != 1 || events[0].Type != EventItemAcquired || events[0].Value != -g.poison.Score {
+		t.Fatalf("events %v", events)
+	}
+	if g.Score() != 100-g.poison.Score || !g.Poisoned() {
+		t.Fatalf("score %d, poisoned %v", g.Score(), g.Poisoned())
+	}
+	if g.Combo() != 3 || g.Multiplier() != 2*1.5 {
+		t.Fatalf("combo %d, multiplier %v", g.Combo(), g.Multiplier())
+	}
+	g.Step([]PlayerControl{PlayerMoveLeft}, 0)
+	if g.Player().Pos() != 1 {
+		t.Fatal("a poisoned player moved")
+	}
+
+	// The penalty holds when the combo continues.
+	g.Step(nil, g.poison.Immobilize+TickDuration)
+	crunchTestChain(g, g.Step)
+	if g.Combo() != 4 || g.Multiplier() != 2.5*1.5 {
+		t.Fatalf("combo %d, multiplier %v", g.Combo(), g.Multiplier())
+	}
+}
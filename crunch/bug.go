@@ -0,0 +1,116 @@
+//go:generate stringer -type=BugType
+
+package crunch
+
+// Color is the a color in a crunch game.
+type Color uint8
+
+// Color constants with special significance.
+const (
+	ColorNone Color = iota
+	ColorBg
+	ColorMulti
+	ColorBomb
+	ColorExploded
+	ColorMoney
+	ColorPoison
+	ColorItem
+	ColorPlayer
+	ColorBug
+)
+
+// BugType enumerates the types of possible bugs
+type BugType uint8
+
+// BugType values that are acceptable
+const (
+	BugSmall BugType = iota
+	BugLarge
+	BugGnat
+	BugMagic
+	BugBomb
+	BugLightning
+	BugRock
+	BugMultiChain
+	bugMax = iota - 1
+	bugNumType
+)
+
+var bugColors = [bugNumType][]Color{
+	BugSmall:      {ColorBug + 0, ColorBug + 1},
+	BugLarge:      {ColorBug + 2, ColorBug + 3},
+	BugGnat:       {ColorNone},
+	BugMagic:      {ColorMulti},
+	BugBomb:       {ColorBomb},
+	BugLightning:  {ColorBomb},
+	BugRock:       {ColorNone},
+	BugMultiChain: {ColorMulti},
+}
+
+var _bugFalls = [bugNumType]bool{
+	BugBomb:      true,
+	BugRock:      true,
+	BugLightning: true,
+}
+
+func bugClimbs(b BugType) bool {
+	return !_bugFalls[b]
+}
+
+// Bug is a bug that crawls down the vines.  Bugs have distinct color.  Large
+// bugs can only eat Small bugs.  Small bugs can only eat Gnats.
+type Bug struct {
+	Type     BugType
+	Color    Color
+	RColor   Color
+	EColor   Color
+	Exploded bool
+	Eaten    int8
+	Rune     rune
+	Items    []*Item
+}
+
+// MaxBugItems is the number of items a bug can hold.
+const MaxBugItems = 3
+
+// holdItems gives items to b.  If b cannot hold them all the items which
+// would be digested soonest are lost.  holdItems returns the lost items.
+func (b *Bug) holdItems(items []*Item) (lost []*Item) {
+	b.Items = append(b.Items, items...)
+	for len(b.Items) > MaxBugItems {
+		k := 0
+		for j := range b.Items {
+			if digestedBefore(b.Items[j], b.Items[k]) {
+				k = j
+			}
+		}
+		lost = append(lost, b.Items[k])
+		copy(b.Items[k:], b.Items[k+1:])
+		b.Items[len(b.Items)-1] = nil
+		b.Items = b.Items[:len(b.Items)-1]
+	}
+	return lost
+}
+
+// digestedBefore returns true if item a is digested before item b.  Items
+// which are never digested have a zero Despawn time.
+func digestedBefore(a, b *Item) bool {
+	if a.Despawn.IsZero() {
+		return false
+	}
+	if b.Despawn.IsZero() {
+		return true
+	}
+	return a.Despawn.Before(b.Despawn)
+}
+
+// ColorEffective returns the currently drawn color for the bug.
+func (b *Bug) ColorEffective() Color {
+	if b.Color != ColorMulti {
+		return b.Color
+	}
+	if b.EColor != ColorNone && b.EColor != ColorMulti {
+		return b.EColor
+	}
+	return b.RColor
+}
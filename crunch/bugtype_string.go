@@ -1,6 +1,6 @@
 // Code generated by "stringer -type=BugType"; DO NOT EDIT
 
-package main
+package crunch
 
 import "fmt"
 
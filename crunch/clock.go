@@ -0,0 +1,120 @@
+package crunch
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time for a game front end.  A Game never reads a clock
+// itself.  Front ends use a Clock to determine how far to Step the game.
+// Substituting a Clock lets timing behavior be reproduced exactly, paused, or
+// sped up.
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock is a Clock that reports the current wall time.
+type RealClock struct{}
+
+var _ Clock = RealClock{}
+
+// Now implements Clock.
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// ManualClock is a Clock that only advances when told to.  ManualClock is safe
+// to use from multiple goroutines.
+type ManualClock struct {
+	mut sync.Mutex
+	now time.Time
+}
+
+var _ Clock = &ManualClock{}
+
+// NewManualClock returns a ManualClock that reports start until it is
+// advanced.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now implements Clock.
+func (c *ManualClock) Now() time.Time {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to t.
+func (c *ManualClock) Set(t time.Time) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.now = t
+}
+
+// ScaledClock is a Clock that runs at a multiple of the speed of another
+// Clock.  A rate of zero pauses the clock.  ScaledClock is safe to use from
+// multiple goroutines.
+type ScaledClock struct {
+	mut   sync.Mutex
+	base  Clock
+	rate  float64
+	since time.Time // base time of the last rate change
+	then  time.Time // scaled time of the last rate change
+}
+
+var _ Clock = &ScaledClock{}
+
+// NewScaledClock returns a ScaledClock that begins at the current time of
+// base and runs at the given rate.
+func NewScaledClock(base Clock, rate float64) *ScaledClock {
+	now := base.Now()
+	return &ScaledClock{
+		base:  base,
+		rate:  rate,
+		since: now,
+		then:  now,
+	}
+}
+
+// Now implements Clock.
+func (c *ScaledClock) Now() time.Time {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.now()
+}
+
+func (c *ScaledClock) now() time.Time {
+	elapsed := c.base.Now().Sub(c.since)
+	return c.then.Add(time.Duration(float64(elapsed) * c.rate))
+}
+
+// Rate returns the current rate of the clock.
+func (c *ScaledClock) Rate() float64 {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.rate
+}
+
+// SetRate changes the rate of the clock without changing its current time.
+func (c *ScaledClock) SetRate(rate float64) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.then = c.now()
+	c.since = c.base.Now()
+	c.rate = rate
+}
+
+// Advance moves the clock forward by d, regardless of its rate.
+func (c *ScaledClock) Advance(d time.Duration) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.then = c.then.Add(d)
+}
@@ -0,0 +1,72 @@
+package crunch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClockSpawn(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	g := newTestGame(t, 1)
+	last := clock.Now()
+	step := func() int {
+		now := clock.Now()
+		events := g.Step(nil, now.Sub(last))
+		last = now
+		return countEvents(events, EventBugSpawned)
+	}
+	for step() == 0 {
+		clock.Advance(TickDuration)
+	}
+
+	// The next spawn is overdue but the game only moves when the clock does.
+	g.bugSpawnTime = g.now
+	for k := 0; k < 1000; k++ {
+		if step() != 0 {
+			t.Fatal("a bug spawned while the clock was stopped")
+		}
+	}
+	if g.Ticks() != int64(clock.Now().Sub(time.Unix(1000, 0))/TickDuration) {
+		t.Fatalf("%d ticks on a clock at %v", g.Ticks(), clock.Now())
+	}
+
+	// Spawns are at least SpawnMinRest apart.
+	for rest := time.Duration(0); rest < SpawnMinRest; rest += TickDuration {
+		clock.Advance(TickDuration)
+		if step() != 0 {
+			t.Fatalf("a bug spawned %v after the last", rest+TickDuration)
+		}
+	}
+	clock.Advance(TickDuration)
+	if step() == 0 {
+		t.Fatalf("no bug spawned %v after the last", SpawnMinRest+TickDuration)
+	}
+}
+
+func TestScaledClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	base := NewManualClock(start)
+	c := NewScaledClock(base, 2)
+	for _, test := range []struct {
+		change func()
+		now    time.Duration
+	}{
+		{func() {}, 0},
+		{func() { base.Advance(time.Second) }, 2 * time.Second},
+		{func() { c.SetRate(0.5) }, 2 * time.Second},
+		{func() { base.Advance(time.Second) }, 2500 * time.Millisecond},
+		{func() { c.SetRate(0) }, 2500 * time.Millisecond},
+		{func() { base.Advance(time.Hour) }, 2500 * time.Millisecond},
+		{func() { c.Advance(time.Second) }, 3500 * time.Millisecond},
+		{func() { c.SetRate(1) }, 3500 * time.Millisecond},
+		{func() { base.Advance(time.Second) }, 4500 * time.Millisecond},
+	} {
+		test.change()
+		if now := c.Now().Sub(start); now != test.now {
+			t.Fatalf("clock at %v (expected %v)", now, test.now)
+		}
+	}
+	if c.Rate() != 1 {
+		t.Fatalf("rate %v", c.Rate())
+	}
+}
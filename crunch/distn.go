@@ -1,9 +1,6 @@
-package main
+package crunch
 
-import (
-	"log"
-	"math/rand"
-)
+import "log"
 
 // Rand wraps PRNG implementations so that behavior of randomized things can be
 // tested more easily.
@@ -79,7 +76,7 @@ func (d intDistn) Rand(r Rand) int {
 	for i := range d {
 		sum += d[i]
 	}
-	roll := rand.Intn(sum)
+	roll := r.Intn(sum)
 	for i := range d {
 		roll -= d[i]
 		if roll < 0 {
@@ -0,0 +1,112 @@
+//go:generate stringer -type=EventType
+
+package crunch
+
+import "image"
+
+// Event describes something that happened during a call to Game.Step.  Pos
+// and Item are only meaningful for some types of events.  Pos is the
+// [column, depth] position of the affected bug or, for events involving the
+// ground, the column of the affected ground slot.
+type Event struct {
+	Type  EventType
+	Pos   image.Point
+	Item  ItemType
+	Value int64
+}
+
+// EventType classifies an Event.
+type EventType uint8
+
+// EventType constants
+const (
+	// EventBugSpawned is emitted when a bug spawns at Pos.
+	EventBugSpawned EventType = iota
+
+	// EventBugGrabbed is emitted when the player grabs the bug at Pos.
+	EventBugGrabbed
+
+	// EventBugSpat is emitted when the player spits a bug onto the vine
+	// without it being eaten.
+	EventBugSpat
+
+	// EventBugFed is emitted when a bug spat by the player is eaten by the
+	// bug at Pos.
+	EventBugFed
+
+	// EventBugExploded is emitted when the bug at Pos explodes.
+	EventBugExploded
+
+	// EventBugDropped is emitted when a bug at Pos falls off its vine.
+	EventBugDropped
+
+	// EventBugLanded is emitted when a bug falls onto the ground at column
+	// Pos.X.
+	EventBugLanded
+
+	// EventGroundBlast is emitted when a bug that landed on the ground at
+	// column Pos.X detonates.  Value holds the number of columns on either
+	// side of Pos.X caught in the blast.
+	EventGroundBlast
+
+	// EventChain is emitted when a chain of Value bugs ends at Pos and drops
+	// money of type Item.
+	EventChain
+
+	// EventCombo is emitted when a chain ending at Pos continues a combo of
+	// Value chains.
+	EventCombo
+
+	// EventItemSpawned is emitted when an item of type Item spawns on the bug
+	// at Pos.
+	EventItemSpawned
+
+	// EventItemDespawned is emitted when an item of type Item held by the bug
+	// at Pos is digested, or is lost because the bug cannot hold any more
+	// items.
+	EventItemDespawned
+
+	// EventItemDropped is emitted when an item falls onto the ground at
+	// column Pos.X.  If the player stands in the column the item is acquired
+	// as soon as it drops.
+	EventItemDropped
+
+	// EventItemAcquired is emitted when the player acquires an item.  Value
+	// holds the number of points the item was worth.  Value is negative for
+	// poison, which costs the player points.
+	EventItemAcquired
+
+	// EventItemUsed is emitted when the player uses an item in column Pos.X.
+	EventItemUsed
+
+	// EventPlayerHit is emitted when the player is hit by a falling bug or
+	// caught in a blast.  Value holds the number of points lost.
+	EventPlayerHit
+
+	// EventStomp is emitted when the player stomps.
+	EventStomp
+
+	// EventPuke is emitted when the player pukes in column Pos.X.  Value
+	// holds the bonus points awarded for feeding the player's young, which
+	// is zero if the player only got rid of a held bug.
+	EventPuke
+
+	// EventLevelUp is emitted when the player reaches level Value.
+	EventLevelUp
+
+	// EventDanger is emitted when a vine becomes full and the next spawn may
+	// end the game.
+	EventDanger
+
+	// EventDangerCleared is emitted when the player has removed the danger
+	// signaled by EventDanger.
+	EventDangerCleared
+
+	// EventGameOver is emitted when a vine has overflowed and the game has
+	// ended.
+	EventGameOver
+
+	// EventGarbage is emitted when garbage sent by an opponent drops onto the
+	// vines.  Value holds the number of bugs dropped.
+	EventGarbage
+)
@@ -0,0 +1,867 @@
+// BUG: Spawn times don't update immediately when you level up but after the
+// next spawn.  This doesn't really seem right.
+
+// Package crunch implements the rules of Cimoj independent of any terminal or
+// rendering library.  A Game holds the board model: vines of bugs, the ground
+// beneath them, and the player.  The game is advanced with Game.Step, which
+// moves the game clock forward, applies player input, and reports what
+// happened as a list of Events.  Front ends observe the board through the
+// Game's read-only accessors.
+package crunch
+
+import (
+	"image"
+	"log"
+	"time"
+)
+
+// Game constants
+const (
+	// Do not allow spawns that are too close to each other to move a space or
+	// two and grab.
+	SpawnMinRest = 30 * time.Millisecond
+
+	// There is a perceptible amount of time between the spawn and you being
+	// able to move again.
+	StompTime  = 150 * time.Millisecond
+	StompSpawn = 10 * time.Millisecond
+	StompRest  = 50 * time.Millisecond
+
+	// PukeTime is how long the player is immobilized after puking.
+	PukeTime = 300 * time.Millisecond
+
+	// GroundFuseTime is the time between a bomb or lightning bug landing on
+	// the ground and its detonation.  LandedRestTime is how long other bugs
+	// that land on the ground remain there.
+	GroundFuseTime = 500 * time.Millisecond
+	LandedRestTime = time.Second
+
+	// GroundItemTime is how long money and poison dropped by bugs remain on
+	// the ground.
+	GroundItemTime = 10 * time.Second
+
+	// MultiColorTime is the time between color changes of multi-colored
+	// bugs.
+	MultiColorTime = 100 * time.Millisecond
+
+	// TickDuration is the resolution of the game clock.  Games advance in
+	// whole ticks so that the outcome of a game depends only on its seed and
+	// the ticks at which player input is applied.
+	TickDuration = 10 * time.Millisecond
+)
+
+// Feeding constants.  Each crunched bug fills the player's meter by one.
+// Puking with at least PukeMeterMin bugs in the meter feeds the player's
+// young, which awards PukePoints for each bug and multiplies the value of
+// items acquired during the following PukeBoostTime.  A full meter doubles
+// item values.
+const (
+	PukeMeterMax  = 24
+	PukeMeterMin  = 6
+	PukePoints    = 5
+	PukeBoostTime = 15 * time.Second
+)
+
+// Combo constants.  A chain that ends within ComboTime of the previous chain
+// continues a combo.  Each chain after the first in a combo raises the score
+// multiplier by ComboStep, up to ComboMaxMultiplier.  The multiplier returns
+// to one when ComboTime passes without a chain.
+const (
+	ComboTime          = 3 * time.Second
+	ComboStep          = 0.5
+	ComboMaxMultiplier = 4
+)
+
+// Config defines the board and the rules of a Game.  Two games with the same
+// Config that receive the same input on the same ticks will be identical.
+//
+// If Puzzle is not nil the game is a puzzle and Survival is ignored.  The
+// board is defined by the puzzle, so NumCol and ColDepth are ignored as well.
+//
+// ChainStep is the time between the stages of a chain reaction.  If ChainStep
+// is zero chain reactions resolve within a single tick.
+type Config struct {
+	NumCol    int
+	ColDepth  int
+	Survival  SurvivalDifficulty
+	Seed      int64
+	Puzzle    *Puzzle
+	ChainStep time.Duration
+}
+
+// Game contains a player, critters, a score, and other game state.
+type Game struct {
+	config             *Config
+	scoreMultiplier    float64
+	combo              int
+	comboExpires       time.Time
+	feedMultiplier     float64
+	feedExpires        time.Time
+	chainSize          int
+	chainEnd           image.Point
+	score              int64
+	scoreThreshold     int64
+	skillLevel         uint32
+	bugDistn           BugDistribution
+	itemDistn          ItemDistribution
+	poison             PoisonRules
+	hazard             HazardRules
+	player             *Player
+	ground             *Ground
+	bugBuffer          []*Bug
+	vines              [][]*Bug
+	next               []*Bug
+	pendingItems       []PendingItem
+	pendingExplos      []image.Point
+	pendingChains      []image.Point
+	pendingMagics      []image.Point
+	pendingFeeds       []image.Point
+	resolveNext        time.Time
+	rand               *countingRand
+	now                time.Time
+	ticks              int64
+	lag                time.Duration
+	bugSpawnInit       bool
+	bugSpawnInitRem    int
+	bugSpawnInitDelay  time.Duration
+	bugRate            float64
+	bugSpawnTime       time.Time
+	bugSpawnLast       time.Time
+	bugSpawnContinue   time.Time
+	bugSpawnStompTime  time.Time
+	bugSpawnStompQueue int
+	itemSpawnRate      float64
+	itemDespawnRate    float64
+	itemSpawnTime      time.Time
+	multis             []*Bug
+	multisTime         time.Time
+	moves              int
+	dying              bool
+	killed             bool
+	over               bool
+	won                bool
+	garbage            Garbage
+	events             []Event
+}
+
+// NewGame initializes a new Game.  The game clock starts at the zero
+// time.Time and only advances when Step is called.
+func NewGame(config *Config) *Game {
+	if config.Puzzle != nil {
+		puzzleConfig := *config
+		puzzleConfig.NumCol = len(config.Puzzle.Vines)
+		puzzleConfig.ColDepth = config.Puzzle.ColDepth()
+		config = &puzzleConfig
+	}
+	g := &Game{
+		config:          config,
+		rand:            newCountingRand(config.Seed),
+		scoreMultiplier: 1,
+		feedMultiplier:  1,
+	}
+	g.vines = make([][]*Bug, config.NumCol)
+	for i := range g.vines {
+		g.vines[i] = make([]*Bug, 0, config.ColDepth+1)
+	}
+
+	g.ground = newGround(config)
+	g.player = newPlayer(config.NumCol)
+
+	if config.Puzzle != nil {
+		g.initPuzzle()
+		return g
+	}
+
+	g.updateSurvivalDifficulty()
+	g.calcBugSpawnTime()
+	g.calcItemSpawnTime()
+
+	return g
+}
+
+// puzzleHazard applies to players in a puzzle, where points and time matter
+// less than in survival.
+var puzzleHazard = HazardRules{Stun: time.Second}
+
+func (g *Game) initPuzzle() {
+	p := g.config.Puzzle
+	g.hazard = puzzleHazard
+	for i, vine := range p.Vines {
+		for _, pbug := range vine {
+			bug := g.createBug(pbug.Type, pbug.Color)
+			if bug.Color == ColorMulti {
+				g.multis = append(g.multis, bug)
+				bug.RColor = g.randMultiColor()
+			}
+			for _, typ := range pbug.Items {
+				// Items placed by a puzzle are never digested.
+				bug.Items = append(bug.Items, &Item{Type: typ})
+			}
+			g.vines[i] = append(g.vines[i], bug)
+		}
+	}
+	for typ := ItemType(0); typ <= itemMax; typ++ {
+		for n := 0; n < p.Inventory[typ]; n++ {
+			g.player.addInv(typ)
+		}
+	}
+}
+
+// Step advances the game clock by dt, resolving the board at each whole tick,
+// and then applies the given player controls in order.  Any fraction of a tick
+// left over is carried into the next call to Step.  Step returns the events
+// that occurred.  The returned slice is only valid until the next call to
+// Step.
+func (g *Game) Step(input []PlayerControl, dt time.Duration) []Event {
+	g.events = g.events[:0]
+
+	g.lag += dt
+	for g.lag >= TickDuration {
+		g.lag -= TickDuration
+		g.tick()
+	}
+
+	if !g.over && !g.gameOver() {
+		for _, pctl := range input {
+			g.control(pctl)
+		}
+	}
+
+	return g.events
+}
+
+func (g *Game) tick() {
+	g.ticks++
+	g.now = g.now.Add(TickDuration)
+
+	if !g.over && g.gameOver() {
+		g.over = true
+		log.Printf("game over")
+		g.emit(Event{Type: EventGameOver})
+	}
+	if !g.over {
+		g.updatePlaying()
+	}
+	if !g.over && g.config.Puzzle != nil {
+		g.checkPuzzleOver()
+	}
+
+	if g.now.Sub(g.multisTime) > MultiColorTime {
+		g.multisTime = g.now
+		g.assignMultiColors()
+	}
+}
+
+func (g *Game) emit(e Event) {
+	g.events = append(g.events, e)
+}
+
+// Config returns the configuration of the game.
+func (g *Game) Config() *Config {
+	return g.config
+}
+
+// Now returns the current time on the game clock.
+func (g *Game) Now() time.Time {
+	return g.now
+}
+
+// Ticks returns the number of whole ticks the game has advanced.  Input given
+// to Step is applied at tick Ticks() after Step returns.
+func (g *Game) Ticks() int64 {
+	return g.ticks
+}
+
+// Seed returns the seed of the game's random number generator.
+func (g *Game) Seed() int64 {
+	return g.config.Seed
+}
+
+// Over returns true if the game has ended.
+func (g *Game) Over() bool {
+	return g.over
+}
+
+// Won returns true if the game was a puzzle that the player solved or a
+// versus game that the player won.
+func (g *Game) Won() bool {
+	return g.won
+}
+
+// Moves returns the number of moves the player has made.  Grabbing a bug,
+// spitting a bug, and using an item are each a move.
+func (g *Game) Moves() int {
+	return g.moves
+}
+
+// MovesLeft returns the number of moves remaining in a puzzle.  MovesLeft
+// returns -1 if the game is not a puzzle.
+func (g *Game) MovesLeft() int {
+	if g.config.Puzzle == nil {
+		return -1
+	}
+	return g.config.Puzzle.Moves - g.moves
+}
+
+// Dying returns true if a vine is full and the next spawn may end the game.
+func (g *Game) Dying() bool {
+	return g.dying
+}
+
+// Score returns the player's current score.
+func (g *Game) Score() int64 {
+	return g.score
+}
+
+// Level returns the player's current skill level.
+func (g *Game) Level() int {
+	return int(g.skillLevel)
+}
+
+// NumCol returns the number of vines on the board.
+func (g *Game) NumCol() int {
+	return len(g.vines)
+}
+
+// Vine returns the bugs on vine i ordered from the canopy down.  A vine
+// holding more than Config.ColDepth bugs has overflowed.  The returned slice
+// and the bugs in it must not be modified.
+func (g *Game) Vine(i int) []*Bug {
+	return g.vines[i]
+}
+
+// Ground returns the ground beneath the vines.
+func (g *Game) Ground() *Ground {
+	return g.ground
+}
+
+// PukeReward returns the bonus points and the item multiplier the player
+// would be awarded for puking now.  If the player's meter is below
+// PukeMeterMin the bonus is zero and the multiplier is one.
+func (g *Game) PukeReward() (bonus int64, multiplier float64) {
+	meter := g.player.meter
+	if meter < PukeMeterMin {
+		return 0, 1
+	}
+	return int64(meter * PukePoints), 1 + float64(meter)/PukeMeterMax
+}
+
+// Combo returns the number of chains in the current combo.  Zero is returned
+// if no combo is in progress.
+func (g *Game) Combo() int {
+	return g.combo
+}
+
+// ComboExpires returns the time at which the current combo ends unless
+// another chain is made.
+func (g *Game) ComboExpires() time.Time {
+	return g.comboExpires
+}
+
+// Settling returns true while a chain reaction on the board is unresolved.
+func (g *Game) Settling() bool {
+	return g.settling()
+}
+
+// Stunned returns true if the player cannot move because they were hit by a
+// falling bug or caught in a blast.
+func (g *Game) Stunned() bool {
+	return g.now.Before(g.player.stunned)
+}
+
+// Poisoned returns true if the player cannot move because they picked up
+// poison.
+func (g *Game) Poisoned() bool {
+	return g.now.Before(g.player.poisoned)
+}
+
+// Multiplier returns the value by which the points of acquired items are
+// multiplied.
+func (g *Game) Multiplier() float64 {
+	return g.scoreMultiplier * g.FeedMultiplier()
+}
+
+// FeedMultiplier returns the multiplier awarded for feeding the player's
+// young.  A multiplier of one is returned if
+// the player has not fed their young recently.
+func (g *Game) FeedMultiplier() float64 {
+	if !g.now.Before(g.feedExpires) {
+		return 1
+	}
+	return g.feedMultiplier
+}
+
+// FeedExpires returns the time at which the current feed multiplier expires.
+func (g *Game) FeedExpires() time.Time {
+	return g.feedExpires
+}
+
+// Player returns the player.
+func (g *Game) Player() *Player {
+	return g.player
+}
+
+func (g *Game) calcItemSpawnTime() {
+	g.itemSpawnTime = g.itemSpawnTime.Add(time.Duration(float64(time.Second)*g.itemSpawnRate + 0.333*g.rand.NormFloat64()))
+}
+
+func (g *Game) calcBugSpawnTime() {
+	// board initialization has completed -- enter the normal code path.
+	if g.bugSpawnInitRem == 0 {
+		g.bugSpawnTime = g.bugSpawnTime.Add(time.Duration(float64(time.Second)*g.bugRate + 0.3333*g.rand.NormFloat64()))
+		return
+	}
+
+	// unknown number of bugs to spawn initially
+	if g.bugSpawnInitDelay == 0 {
+		g.bugSpawnTime = g.bugSpawnTime.Add(time.Second)
+		return
+	}
+
+	g.bugSpawnTime = g.bugSpawnTime.Add(g.bugSpawnInitDelay)
+}
+
+func (g *Game) updateSurvivalDifficulty() bool {
+	levelup := false
+	for g.scoreThreshold >= 0 && g.score >= g.scoreThreshold {
+		levelup = true
+		g.skillLevel++
+		g.scoreThreshold = g.config.Survival.NextLevel(int(g.skillLevel))
+	}
+	if levelup {
+		log.Printf("level=%d", g.skillLevel)
+		g.emit(Event{Type: EventLevelUp, Value: int64(g.skillLevel)})
+		diff := g.config.Survival
+		g.bugRate = diff.BugRate(int(g.skillLevel))
+		g.bugDistn = diff.BugDistribution(int(g.skillLevel))
+		g.itemDistn = diff.ItemDistribution(int(g.skillLevel))
+		spawn, despawn := diff.ItemRate(int(g.skillLevel))
+		g.itemSpawnRate = spawn
+		g.itemDespawnRate = despawn
+		g.poison = diff.Poison(int(g.skillLevel))
+		g.hazard = diff.Hazard(int(g.skillLevel))
+		if !g.bugSpawnInit {
+			g.bugSpawnInit = true
+			g.bugSpawnInitRem = diff.NumBugInit()
+			if g.bugSpawnInitRem == 0 {
+				g.bugSpawnInitRem = 3
+			}
+			g.bugSpawnInitDelay = time.Duration(float64(time.Second) * diff.BugRateInit())
+		}
+	}
+	return levelup
+}
+
+func (g *Game) gameOver() bool {
+	if g.killed {
+		return true
+	}
+	for i := range g.vines {
+		if len(g.vines[i]) > g.config.ColDepth {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Game) updatePlaying() {
+	// Nothing spawns in a puzzle.
+	puzzle := g.config.Puzzle != nil
+
+	// Bugs wait for chain reactions to finish before spawning.
+	if !puzzle && !g.settling() {
+		g.checkSpawnBugs()
+		g.dropGarbage()
+	}
+
+	g.checkComboExpired()
+
+	// Clear things and combo as many times as necessary.  If the number of if
+	// the player was able to save themselves from death make sure to clear the
+	// "dying" state.
+	g.resolve()
+	g.checkDyingRemedied()
+	g.checkLanded()
+
+	if puzzle {
+		g.ground.despawnItems(g.now)
+		return
+	}
+
+	g.checkSpawnItems()
+
+	g.updateSurvivalDifficulty()
+}
+
+// resolve advances the chain reactions on the board.  If the game has a
+// ChainStep the reactions advance one stage at a time so the player can watch
+// them.  Otherwise reactions resolve completely.
+func (g *Game) resolve() {
+	if g.config.ChainStep <= 0 {
+		for g.resolveStage() {
+		}
+		return
+	}
+	if g.now.Before(g.resolveNext) {
+		return
+	}
+	if g.resolveStage() {
+		g.resolveNext = g.now.Add(g.config.ChainStep)
+	}
+}
+
+// resolveStage advances the chain reactions on the board by one stage and
+// returns true if there was anything to resolve.  Exploded bugs are cleared
+// away in one stage, which may queue food chains.  Each link of a food chain
+// is a stage of its own.  Pending reactions are triggered once no food chains
+// remain.
+func (g *Game) resolveStage() bool {
+	if g.hasExploded() {
+		g.clearExploded()
+		return true
+	}
+	if len(g.pendingFeeds) > 0 {
+		g.feed()
+		return true
+	}
+	if g.settling() {
+		g.triggerExplosions()
+		return true
+	}
+	return false
+}
+
+// continueCombo is called each time a chain ends and raises the score
+// multiplier if the chain continues a combo.
+func (g *Game) continueCombo() {
+	if g.combo > 0 && g.now.Before(g.comboExpires) {
+		g.combo++
+	} else {
+		g.combo = 1
+	}
+	g.comboExpires = g.now.Add(ComboTime)
+	g.scoreMultiplier = comboMultiplier(g.combo)
+	if g.combo > 1 {
+		log.Printf("combo=%d multiplier=%.2f", g.combo, g.scoreMultiplier)
+		g.emit(Event{Type: EventCombo, Pos: g.chainEnd, Value: int64(g.combo)})
+	}
+}
+
+// comboMultiplier returns the score multiplier for a combo of the given
+// number of chains.
+func comboMultiplier(combo int) float64 {
+	if combo <= 1 {
+		return 1
+	}
+	m := 1 + ComboStep*float64(combo-1)
+	if m > ComboMaxMultiplier {
+		return ComboMaxMultiplier
+	}
+	return m
+}
+
+// checkComboExpired ends the current combo once ComboTime has passed without
+// a chain.
+func (g *Game) checkComboExpired() {
+	if g.combo == 0 || g.now.Before(g.comboExpires) {
+		return
+	}
+	log.Printf("combo=%d ended", g.combo)
+	g.combo = 0
+	g.scoreMultiplier = 1
+}
+
+// checkPuzzleOver ends a puzzle once the vines are clear or the player has run
+// out of moves.  The board has settled by the time checkPuzzleOver is called
+// so a chain set off by the final move counts toward solving the puzzle.
+func (g *Game) checkPuzzleOver() {
+	if g.settling() {
+		return
+	}
+	clear := g.player.contains == nil
+	for i := range g.vines {
+		clear = clear && len(g.vines[i]) == 0
+	}
+	if !clear && g.MovesLeft() > 0 {
+		return
+	}
+	g.over = true
+	g.won = clear
+	log.Printf("puzzle over solved=%v moves=%d", g.won, g.moves)
+	g.emit(Event{Type: EventGameOver})
+}
+
+func (g *Game) checkSpawnBugs() {
+	// The next row is chosen ahead of time so the player can see it coming.
+	g.chooseNext()
+
+	now := g.now
+	if !now.After(g.bugSpawnContinue) {
+		return
+	}
+
+	if now.After(g.bugSpawnTime) {
+		g.bugSpawnTime = now
+		g.bugSpawnLast = now
+		g.bugSpawnContinue = now.Add(SpawnMinRest)
+		g.spawnBugs()
+		g.calcBugSpawnTime()
+		g.checkDanger()
+		return
+	}
+
+	if !g.bugSpawnStompTime.IsZero() && now.After(g.bugSpawnStompTime) {
+		if g.bugSpawnStompQueue <= 1 {
+			g.bugSpawnStompQueue = 0
+			g.bugSpawnStompTime = time.Time{}
+		} else {
+			g.bugSpawnStompQueue--
+		}
+		g.bugSpawnLast = now
+		g.bugSpawnContinue = now.Add(SpawnMinRest)
+		g.spawnBugs()
+		return
+	}
+}
+
+// checkDanger signals danger if any vine is full.
+func (g *Game) checkDanger() {
+	for i := range g.vines {
+		if len(g.vines[i]) == g.config.ColDepth {
+			if !g.dying {
+				g.emit(Event{Type: EventDanger})
+			}
+			g.dying = true
+		}
+	}
+}
+
+// Next returns the row of bugs that will spawn next, indexed by column.  Next
+// returns nil in puzzles and before the first row has been chosen.  The
+// returned slice must not be modified.
+func (g *Game) Next() []*Bug {
+	return g.next
+}
+
+// NextSpawn returns the time at which the next row of bugs is due to spawn
+// and the time at which the previous row spawned.  Stomping brings the next
+// row in early.
+func (g *Game) NextSpawn() (next, last time.Time) {
+	next = g.bugSpawnTime
+	if g.SpawnPulled() && g.bugSpawnStompTime.Before(next) {
+		next = g.bugSpawnStompTime
+	}
+	if next.Before(g.bugSpawnContinue) {
+		next = g.bugSpawnContinue
+	}
+	return next, g.bugSpawnLast
+}
+
+// SpawnPulled returns true if the player has stomped to bring the next row of
+// bugs in early.
+func (g *Game) SpawnPulled() bool {
+	return g.bugSpawnStompQueue > 0
+}
+
+func (g *Game) checkDyingRemedied() {
+	if g.dying {
+		remedied := true
+		for i := range g.vines {
+			if len(g.vines[i]) == g.config.ColDepth {
+				remedied = false
+				break
+			}
+		}
+		if remedied {
+			g.dying = false
+			g.emit(Event{Type: EventDangerCleared})
+		}
+	}
+}
+
+func (g *Game) checkSpawnItems() {
+	g.despawnItems()
+	g.ground.despawnItems(g.now)
+
+	if g.now.After(g.itemSpawnTime) {
+		g.itemSpawnTime = g.now
+		g.spawnNewItem()
+		g.calcItemSpawnTime()
+		return
+	}
+}
+
+func (g *Game) despawnItems() {
+	for i := range g.vines {
+		for j := range g.vines[i] {
+			bug := g.vines[i][j]
+			var k int
+			for _, item := range bug.Items {
+				if !item.expired(g.now) {
+					bug.Items[k] = item
+					k++
+					continue
+				}
+				log.Printf("pos=[%d, %d] type=%v item despawned", i, j, item.Type)
+				g.emit(Event{Type: EventItemDespawned, Pos: image.Pt(i, j), Item: item.Type})
+			}
+			for l := k; l < len(bug.Items); l++ {
+				bug.Items[l] = nil
+			}
+			bug.Items = bug.Items[:k]
+		}
+	}
+}
+
+func (g *Game) spawnNewItem() {
+	numBug := 0
+	for i := range g.vines {
+		numBug += len(g.vines[i])
+	}
+	if numBug == 0 {
+		// No bugs to hold the new item... Too bad?
+		return
+	}
+
+	chosenBug := g.rand.Intn(numBug)
+	for i := range g.vines {
+		if chosenBug >= len(g.vines[i]) {
+			chosenBug -= len(g.vines[i])
+			continue
+		}
+		g.spawnNewItemAt(i, chosenBug)
+		break
+	}
+}
+
+func (g *Game) spawnNewItemAt(i, j int) {
+	bug := g.vines[i][j]
+	typ := g.itemDistn.RandItemType(g.rand)
+	if len(bug.Items) >= MaxBugItems {
+		log.Printf("pos=[%d, %d] type=%v item not spawned on a full bug", i, j, typ)
+		return
+	}
+	log.Printf("pos=[%d, %d] type=%v item spawned", i, j, typ)
+	bug.Items = append(bug.Items, &Item{
+		Type:    typ,
+		Despawn: g.getItemDespawnTime(),
+	})
+	g.emit(Event{Type: EventItemSpawned, Pos: image.Pt(i, j), Item: typ})
+}
+
+func (g *Game) getItemDespawnTime() time.Time {
+	return g.now.Add(time.Duration(float64(time.Second)*g.itemDespawnRate + 0.333*g.rand.NormFloat64()))
+}
+
+func (g *Game) control(pctl PlayerControl) {
+	now := g.now
+	// Do not accept movement input if the player is immobilized.
+	if !now.After(g.player.immobilized) {
+		return
+	}
+	// A puzzle accepts no moves once its budget is spent.
+	if g.MovesLeft() == 0 {
+		return
+	}
+	g.player.clearStomp(now)
+	switch pctl {
+	case PlayerMoveLeft:
+		g.controlMoveLeft()
+	case PlayerMoveRight:
+		g.controlMoveRight()
+	case PlayerGrabSpit:
+		g.controlGrabSpit()
+	case PlayerStomp:
+		g.controlStomp()
+	case PlayerPuke:
+		g.controlPuke()
+	case PlayerItemUse:
+		g.controlPlayerItemUse()
+	case PlayerItemForward:
+		g.controlPlayerItemForward()
+	case PlayerItemBackward:
+		g.controlPlayerItemBackward()
+	}
+}
+
+func (g *Game) controlMoveLeft() {
+	if g.player.pos > 0 {
+		g.player.pos--
+		g.pickUpItems(g.player.pos)
+	}
+}
+
+func (g *Game) controlMoveRight() {
+	if g.player.pos < g.config.NumCol {
+		g.player.pos++
+		g.pickUpItems(g.player.pos)
+	}
+}
+
+func (g *Game) controlGrabSpit() {
+	// The player cannot reach into a chain reaction.
+	if g.columnSettling(g.player.pos) {
+		return
+	}
+	var moved bool
+	if g.player.contains != nil {
+		moved = g.spitBug(g.player.pos)
+	} else {
+		moved = g.grabBug(g.player.pos)
+	}
+	if moved {
+		g.moves++
+	}
+}
+
+func (g *Game) controlStomp() {
+	if g.player.beginStomp(g.now) {
+		g.bugSpawnStompQueue++
+		g.bugSpawnStompTime = g.now.Add(StompTime + StompSpawn)
+		g.emit(Event{Type: EventStomp})
+	}
+}
+
+func (g *Game) controlPuke() {
+	if g.player.contains == nil && g.player.meter < PukeMeterMin {
+		return
+	}
+	g.moves++
+	g.player.immobilized = g.now.Add(PukeTime)
+	if g.player.contains != nil {
+		log.Printf("held bug puked")
+		g.removeBug(g.player.contains)
+		g.player.contains = nil
+	}
+
+	bonus, mult := g.PukeReward()
+	if bonus > 0 {
+		log.Printf("meter=%d bonus=%d multiplier=%.2f young fed", g.player.meter, bonus, mult)
+		g.player.meter = 0
+		g.score += bonus
+		g.feedMultiplier = mult
+		g.feedExpires = g.now.Add(PukeBoostTime)
+	}
+	g.emit(Event{Type: EventPuke, Pos: image.Pt(g.player.pos, 0), Value: bonus})
+}
+
+func (g *Game) controlPlayerItemUse() {
+	typ, ok := g.player.useInv()
+	if !ok {
+		return
+	}
+	g.moves++
+	g.pendingItems = append(g.pendingItems, PendingItem{
+		Type: typ,
+		Col:  g.player.pos,
+	})
+	g.emit(Event{Type: EventItemUsed, Pos: image.Pt(g.player.pos, 0), Item: typ})
+}
+
+func (g *Game) controlPlayerItemForward() {
+	g.player.rotateInv(-1)
+}
+
+func (g *Game) controlPlayerItemBackward() {
+	g.player.rotateInv(1)
+}
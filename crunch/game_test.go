@@ -0,0 +1,467 @@
+package crunch
+
+import (
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// The engine logs every move.
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+// readTestProfile reads one of the profiles shipped with the game.
+func readTestProfile(t testing.TB, name string) *Profile {
+	f, err := os.Open("../profiles/" + name + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p, err := ReadProfile(f)
+	if err != nil {
+		t.Fatalf("profile %s: %v", name, err)
+	}
+	return p
+}
+
+func newTestGame(t testing.TB, seed int64) *Game {
+	return NewGame(&Config{
+		NumCol:   8,
+		ColDepth: 7,
+		Survival: readTestProfile(t, "normal"),
+		Seed:     seed,
+	})
+}
+
+// newTestBoard returns a survival game whose vines hold the given bugs.
+// Nothing spawns on the board during a test.
+func newTestBoard(t testing.TB, vines ...[]*Bug) *Game {
+	g := newTestGame(t, 1)
+	for i, vine := range vines {
+		g.vines[i] = append(g.vines[i], vine...)
+	}
+	g.bugSpawnTime = g.now.Add(time.Hour)
+	g.itemSpawnTime = g.now.Add(time.Hour)
+	return g
+}
+
+func testBug(typ BugType, c int) *Bug {
+	return &Bug{Type: typ, Color: ColorBug + Color(c)}
+}
+
+// crunchTestChain sets off a chain of one bug beneath the player, using step
+// to advance the game, and returns the events of the tick that ends it.
+func crunchTestChain(g *Game, step func([]PlayerControl, time.Duration) []Event) []Event {
+	i := g.player.pos
+	bug := testBug(BugLarge, 2)
+	bug.Eaten = 1
+	g.vines[i] = append(g.vines[i], bug)
+	g.player.contains = testBug(BugSmall, 0)
+	step([]PlayerControl{PlayerGrabSpit}, 0)
+	return step(nil, TickDuration)
+}
+
+func countEvents(events []Event, typ EventType) int {
+	var n int
+	for _, e := range events {
+		if e.Type == typ {
+			n++
+		}
+	}
+	return n
+}
+
+func TestGameStepTicks(t *testing.T) {
+	g := newTestGame(t, 1)
+	if g.Ticks() != 0 || !g.Now().IsZero() {
+		t.Fatalf("new game at tick %d, time %v", g.Ticks(), g.Now())
+	}
+	for _, test := range []struct {
+		dt    time.Duration
+		ticks int64
+	}{
+		{0, 0},
+		{TickDuration / 2, 0},
+		{TickDuration / 2, 1},
+		{25 * time.Millisecond, 3},
+		{5 * time.Millisecond, 4},
+		{time.Second, 104},
+	} {
+		g.Step(nil, test.dt)
+		if g.Ticks() != test.ticks {
+			t.Fatalf("step %v: tick %d (expected %d)", test.dt, g.Ticks(), test.ticks)
+		}
+		want := time.Time{}.Add(time.Duration(test.ticks) * TickDuration)
+		if !g.Now().Equal(want) {
+			t.Fatalf("step %v: time %v (expected %v)", test.dt, g.Now(), want)
+		}
+	}
+}
+
+func TestGameStepEvents(t *testing.T) {
+	g := newTestGame(t, 1)
+	var spawned int
+	for k := 0; k < 500 && spawned == 0; k++ {
+		events := g.Step(nil, TickDuration)
+		spawned += countEvents(events, EventBugSpawned)
+		for _, e := range events {
+			if e.Type == EventBugSpawned && len(g.Vine(e.Pos.X)) == 0 {
+				t.Fatalf("bug spawned on empty vine %d", e.Pos.X)
+			}
+		}
+	}
+	if spawned == 0 {
+		t.Fatal("no bugs spawned")
+	}
+
+	// A step that spans no tick reports nothing.
+	events := g.Step(nil, 0)
+	if len(events) != 0 {
+		t.Fatalf("events without a tick: %v", events)
+	}
+}
+
+func TestGameStepTickBeforeInput(t *testing.T) {
+	g := newTestGame(t, 1)
+	pos := g.Player().Pos()
+
+	// The player can move once the clock passes the end of the
+	// immobilization, which happens during the next tick.
+	g.player.immobilized = g.now.Add(TickDuration / 2)
+	g.Step([]PlayerControl{PlayerMoveLeft}, 0)
+	if g.Player().Pos() != pos {
+		t.Fatal("input applied before the tick")
+	}
+	g.Step([]PlayerControl{PlayerMoveLeft}, TickDuration)
+	if g.Player().Pos() != pos-1 {
+		t.Fatalf("input not applied after the tick: pos %d", g.Player().Pos())
+	}
+
+	// Controls are applied in order.
+	g.Step([]PlayerControl{PlayerMoveLeft, PlayerMoveLeft, PlayerMoveRight}, TickDuration)
+	if g.Player().Pos() != pos-2 {
+		t.Fatalf("pos %d (expected %d)", g.Player().Pos(), pos-2)
+	}
+}
+
+func TestGameOver(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	last := clock.Now()
+	step := func(g *Game, input []PlayerControl) []Event {
+		clock.Advance(16 * time.Millisecond)
+		now := clock.Now()
+		dt := now.Sub(last)
+		last = now
+		return g.Step(input, dt)
+	}
+
+	// Left alone the vines overflow.
+	g := newTestGame(t, 2)
+	var over int
+	for k := 0; k < 100000 && !g.Over(); k++ {
+		over += countEvents(step(g, nil), EventGameOver)
+	}
+	if !g.Over() {
+		t.Fatal("game never ended")
+	}
+	if over != 1 {
+		t.Fatalf("%d game over events", over)
+	}
+	if g.Won() {
+		t.Fatal("lost game was won")
+	}
+
+	// Nothing happens once the game is over.
+	ticks, score, pos := g.Ticks(), g.Score(), g.Player().Pos()
+	for k := 0; k < 100; k++ {
+		events := step(g, []PlayerControl{PlayerMoveLeft, PlayerGrabSpit})
+		if len(events) != 0 {
+			t.Fatalf("events after game over: %v", events)
+		}
+	}
+	if g.Ticks() <= ticks {
+		t.Fatal("clock stopped after game over")
+	}
+	if g.Score() != score || g.Player().Pos() != pos {
+		t.Fatal("game changed after game over")
+	}
+}
+
+// testInput returns a stream of random controls, one slice per tick.  The
+// player never stomps, which would hasten the end of the game.
+func testInput(seed int64, ticks int) [][]PlayerControl {
+	r := rand.New(rand.NewSource(seed))
+	input := make([][]PlayerControl, ticks)
+	for k := range input {
+		if r.Intn(4) != 0 {
+			continue
+		}
+		ctl := PlayerControl(r.Intn(int(PlayerItemBackward) + 1))
+		if ctl == PlayerStomp {
+			ctl = PlayerGrabSpit
+		}
+		input[k] = []PlayerControl{ctl}
+	}
+	return input
+}
+
+func TestGameSeedDeterminism(t *testing.T) {
+	input := testInput(7, 6000)
+	a := newTestGame(t, 3)
+	b := newTestGame(t, 3)
+	var hashes []uint64
+	for k, in := range input {
+		a.Step(in, TickDuration)
+		// The second game is stepped in uneven pieces, which must not matter.
+		b.Step(nil, 3*time.Millisecond)
+		b.Step(nil, 3*time.Millisecond)
+		b.Step(in, 4*time.Millisecond)
+		if a.Hash() != b.Hash() || a.Score() != b.Score() || a.Level() != b.Level() {
+			t.Fatalf("tick %d: games differ: hash %x %x, score %d %d, level %d %d",
+				k, a.Hash(), b.Hash(), a.Score(), b.Score(), a.Level(), b.Level())
+		}
+		hashes = append(hashes, a.Hash())
+		if a.Over() {
+			break
+		}
+	}
+
+	if len(hashes) < 1000 {
+		t.Errorf("game too short to compare: %d ticks", len(hashes))
+	}
+
+	c := newTestGame(t, 4)
+	for k, in := range input[:len(hashes)] {
+		c.Step(in, TickDuration)
+		if c.Hash() != hashes[k] {
+			return
+		}
+	}
+	t.Error("games with different seeds are the same")
+}
+
+func TestPuke(t *testing.T) {
+	g := newTestBoard(t)
+	g.player.pos = 1
+	g.Step(nil, TickDuration)
+
+	// A player with nothing to puke cannot puke.
+	events := g.Step([]PlayerControl{PlayerPuke}, 0)
+	if countEvents(events, EventPuke) != 0 {
+		t.Fatal("puked with an empty meter")
+	}
+
+	// Each crunched bug fills the meter.
+	for k := 0; k < PukeMeterMin; k++ {
+		crunchTestChain(g, g.Step)
+		if g.Player().Meter() != k+1 {
+			t.Fatalf("meter %d after %d chains", g.Player().Meter(), k+1)
+		}
+	}
+
+	bonus, mult := g.PukeReward()
+	if bonus != PukeMeterMin*PukePoints || mult <= 1 {
+		t.Fatalf("reward %d, multiplier %v", bonus, mult)
+	}
+	g.player.contains = testBug(BugSmall, 0)
+	score := g.Score()
+	events = g.Step([]PlayerControl{PlayerPuke}, 0)
+	if len(events) != 1 || events[0].Type != EventPuke || events[0].Value != bonus {
+		t.Fatalf("events %v", events)
+	}
+	if g.Player().Meter() != 0 || g.Player().Contains() != nil {
+		t.Fatalf("meter %d, holding %v after puking", g.Player().Meter(), g.Player().Contains())
+	}
+	if g.Score() != score+bonus || g.FeedMultiplier() != mult {
+		t.Fatalf("score %d, feed multiplier %v", g.Score(), g.FeedMultiplier())
+	}
+	g.Step([]PlayerControl{PlayerMoveLeft}, TickDuration)
+	if g.Player().Pos() != 1 {
+		t.Fatal("moved while puking")
+	}
+
+	// The multiplier lasts PukeBoostTime.  A held bug can be puked with an
+	// empty meter, for no reward.
+	g.Step(nil, PukeBoostTime)
+	if g.FeedMultiplier() != 1 {
+		t.Fatalf("feed multiplier %v after %v", g.FeedMultiplier(), PukeBoostTime)
+	}
+	g.player.contains = testBug(BugSmall, 0)
+	events = g.Step([]PlayerControl{PlayerPuke}, 0)
+	if len(events) != 1 || events[0].Type != EventPuke || events[0].Value != 0 || g.Player().Contains() != nil {
+		t.Fatalf("events %v, holding %v", events, g.Player().Contains())
+	}
+}
+
+func TestCombo(t *testing.T) {
+	g := newTestBoard(t)
+	g.player.pos = 0
+	clock := NewManualClock(time.Unix(0, 0))
+	last := clock.Now()
+	step := func(input []PlayerControl, d time.Duration) []Event {
+		clock.Advance(d)
+		now := clock.Now()
+		dt := now.Sub(last)
+		last = now
+		return g.Step(input, dt)
+	}
+	step(nil, TickDuration)
+
+	events := crunchTestChain(g, step)
+	if g.Combo() != 1 || g.Multiplier() != 1 || countEvents(events, EventCombo) != 0 {
+		t.Fatalf("combo %d, multiplier %v after one chain", g.Combo(), g.Multiplier())
+	}
+
+	// A chain within ComboTime of the last continues the combo, and its
+	// money is worth more.
+	step(nil, ComboTime/2)
+	score := g.Score()
+	events = crunchTestChain(g, step)
+	if g.Combo() != 2 || g.Multiplier() != 1+ComboStep || countEvents(events, EventCombo) != 1 {
+		t.Fatalf("combo %d, multiplier %v after two chains", g.Combo(), g.Multiplier())
+	}
+	if g.Score()-score != int64(float64(g.pointValue(ItemMoneyXXS))*(1+ComboStep)) {
+		t.Fatalf("chain worth %d points", g.Score()-score)
+	}
+
+	// The multiplier stops rising at ComboMaxMultiplier.
+	for g.Combo() < 10 {
+		step(nil, ComboTime-2*TickDuration)
+		crunchTestChain(g, step)
+	}
+	if g.Multiplier() != ComboMaxMultiplier {
+		t.Fatalf("multiplier %v after %d chains", g.Multiplier(), g.Combo())
+	}
+
+	// The combo ends once ComboTime passes without a chain.
+	step(nil, ComboTime-TickDuration)
+	if g.Combo() != 10 {
+		t.Fatal("the combo ended early")
+	}
+	step(nil, TickDuration)
+	if g.Combo() != 0 || g.Multiplier() != 1 {
+		t.Fatalf("combo %d, multiplier %v after %v", g.Combo(), g.Multiplier(), ComboTime)
+	}
+	crunchTestChain(g, step)
+	if g.Combo() != 1 || g.Multiplier() != 1 {
+		t.Fatalf("combo %d, multiplier %v in a new combo", g.Combo(), g.Multiplier())
+	}
+}
+
+// newTestCascade returns a board where a chain reaction takes four stages to
+// resolve.  A gap closes, a food chain fills a large bug, a chain crunches it
+// and its neighbor, and the gaps they leave close.
+func newTestCascade(t *testing.T, chainStep time.Duration) *Game {
+	large := testBug(BugLarge, 2)
+	large.Eaten = 1
+	gap := testBug(BugSmall, 1)
+	gap.Exploded = true
+	g := newTestBoard(t,
+		[]*Bug{large, gap, testBug(BugSmall, 0)},
+		[]*Bug{testBug(BugLarge, 2), testBug(BugSmall, 0), {Type: BugGnat}},
+		nil,
+		[]*Bug{testBug(BugSmall, 1)},
+	)
+	g.config.ChainStep = chainStep
+	g.player.pos = 3
+	return g
+}
+
+func TestChainStep(t *testing.T) {
+	const chainStep = 100 * time.Millisecond
+	instant := newTestCascade(t, 0)
+	instant.Step(nil, TickDuration)
+	if instant.Settling() {
+		t.Fatal("the cascade did not resolve in one tick")
+	}
+
+	g := newTestCascade(t, chainStep)
+	for g.Settling() {
+		g.Step(nil, TickDuration)
+	}
+	if g.Ticks() != 1+3*int64(chainStep/TickDuration) {
+		t.Fatalf("the cascade resolved in %d ticks", g.Ticks())
+	}
+
+	// Both games reach the same board.
+	for i := 0; i < g.NumCol(); i++ {
+		a, b := instant.Vine(i), g.Vine(i)
+		same := len(a) == len(b)
+		for j := 0; same && j < len(a); j++ {
+			same = a[j].Type == b[j].Type && a[j].Color == b[j].Color && a[j].Eaten == b[j].Eaten
+		}
+		if !same || len(instant.Ground().Slot(i)) != len(g.Ground().Slot(i)) {
+			t.Fatalf("column %d differs", i)
+		}
+	}
+	if len(g.Vine(0)) != 0 || len(g.Vine(1)) != 2 || g.Score() != instant.Score() {
+		t.Fatalf("columns of %d and %d bugs, score %d", len(g.Vine(0)), len(g.Vine(1)), g.Score())
+	}
+}
+
+func TestChainStepInput(t *testing.T) {
+	g := newTestCascade(t, 100*time.Millisecond)
+	g.Step(nil, 5*TickDuration)
+	if !g.Settling() {
+		t.Fatal("the cascade resolved")
+	}
+	n := len(g.Vine(0))
+
+	// The player may grab from a vine that is not settling and move while
+	// the cascade resolves, but not spit onto a settling vine.
+	g.Step([]PlayerControl{PlayerGrabSpit, PlayerMoveLeft, PlayerMoveLeft, PlayerMoveLeft, PlayerGrabSpit}, 0)
+	if g.Player().Contains() == nil || g.Player().Pos() != 0 {
+		t.Fatalf("holding %v at %d", g.Player().Contains(), g.Player().Pos())
+	}
+	if !g.Settling() || len(g.Vine(0)) != n {
+		t.Fatalf("column of %d bugs", len(g.Vine(0)))
+	}
+}
+
+func TestStompSpawnsNext(t *testing.T) {
+	g := newTestGame(t, 1)
+	for g.bugSpawnInitRem > 0 {
+		g.Step(nil, TickDuration)
+	}
+	g.bugSpawnTime = g.now.Add(time.Hour)
+	g.Step(nil, SpawnMinRest)
+	next := append([]*Bug(nil), g.Next()...)
+	if len(next) != g.NumCol() {
+		t.Fatalf("%d bugs in the next row", len(next))
+	}
+
+	events := g.Step([]PlayerControl{PlayerStomp}, 0)
+	if countEvents(events, EventStomp) != 1 || !g.SpawnPulled() {
+		t.Fatal("the player did not stomp")
+	}
+	due, _ := g.NextSpawn()
+	if !due.Equal(g.now.Add(StompTime + StompSpawn)) {
+		t.Fatalf("the next row is due in %v", due.Sub(g.now))
+	}
+	var spawned int
+	for g.now.Before(due) {
+		spawned += countEvents(g.Step(nil, TickDuration), EventBugSpawned)
+	}
+	spawned += countEvents(g.Step(nil, TickDuration), EventBugSpawned)
+	if spawned != g.NumCol() || g.SpawnPulled() {
+		t.Fatalf("%d bugs spawned", spawned)
+	}
+
+	// The previewed row is the row that spawned, and a new row is chosen.
+	for i, bug := range next {
+		if g.Vine(i)[0] != bug {
+			t.Fatalf("vine %d: the previewed bug did not spawn", i)
+		}
+	}
+	g.Step(nil, TickDuration)
+	for i, bug := range next {
+		if g.Next()[i] == nil || g.Next()[i] == bug {
+			t.Fatalf("vine %d: no new bug chosen", i)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package crunch
+
+import (
+	"log"
+	"time"
+)
+
+// Ground holds items that the player can pick up.  Each column has a slot on
+// the ground beneath its vine.  Bugs that fall off a vine land in the slot
+// beneath it.
+type Ground struct {
+	slots  [][]*Item
+	landed [][]*Landed
+}
+
+// Landed is a bug that fell onto the ground.  Bombs and lightning bugs
+// detonate at Time.  Other bugs crumble away at Time.
+type Landed struct {
+	Bug  *Bug
+	Time time.Time
+}
+
+func newGround(config *Config) *Ground {
+	g := &Ground{}
+	g.slots = make([][]*Item, config.NumCol)
+	g.landed = make([][]*Landed, config.NumCol)
+	for i := range g.slots {
+		g.slots[i] = make([]*Item, 0, config.ColDepth)
+	}
+	return g
+}
+
+// Slot returns the items on the ground beneath column i.  The returned slice
+// must not be modified.
+func (g *Ground) Slot(i int) []*Item {
+	if i < 0 || i >= len(g.slots) {
+		return nil
+	}
+	return g.slots[i]
+}
+
+// Landed returns the bugs that have landed on the ground beneath column i.
+// The returned slice must not be modified.
+func (g *Ground) Landed(i int) []*Landed {
+	if i < 0 || i >= len(g.landed) {
+		return nil
+	}
+	return g.landed[i]
+}
+
+func (g *Ground) land(i int, bug *Bug, t time.Time) {
+	g.landed[i] = append(g.landed[i], &Landed{Bug: bug, Time: t})
+}
+
+// takeLanded removes and returns the bugs in column i whose time has come.
+// If all is true every bug in the column is removed.
+func (g *Ground) takeLanded(now time.Time, i int, all bool) []*Landed {
+	var taken []*Landed
+	var k int
+	for j := range g.landed[i] {
+		if all || !now.Before(g.landed[i][j].Time) {
+			taken = append(taken, g.landed[i][j])
+			continue
+		}
+		g.landed[i][k] = g.landed[i][j]
+		k++
+	}
+	for j := k; j < len(g.landed[i]); j++ {
+		g.landed[i][j] = nil
+	}
+	g.landed[i] = g.landed[i][:k]
+	return taken
+}
+
+// clearItems destroys the items beneath column i.
+func (g *Ground) clearItems(i int) {
+	for j := range g.slots[i] {
+		g.slots[i][j] = nil
+	}
+	g.slots[i] = g.slots[i][:0]
+}
+
+func (g *Ground) takeItems(now time.Time, i int) []*Item {
+	if i >= len(g.slots) {
+		return nil
+	}
+	if len(g.slots[i]) == 0 {
+		return nil
+	}
+
+	var k int
+	for j := range g.slots[i] {
+		if g.slots[i][j].expired(now) {
+			continue
+		}
+		g.slots[i][k] = g.slots[i][j]
+		k++
+	}
+	items := make([]*Item, k)
+	copy(items, g.slots[i])
+	for j := range g.slots[i] {
+		g.slots[i][j] = nil
+	}
+	g.slots[i] = g.slots[i][:0]
+
+	return items
+}
+
+func (g *Ground) despawnItems(now time.Time) {
+	for i := range g.slots {
+		var k int
+		for j := range g.slots[i] {
+			if g.slots[i][j].expired(now) {
+				continue
+			}
+			g.slots[i][k] = g.slots[i][j]
+			k++
+		}
+		for j := k; j < len(g.slots[i]); j++ {
+			g.slots[i][j] = nil
+		}
+		g.slots[i] = g.slots[i][:k]
+	}
+}
+
+// insertItem puts item in the slot beneath column i.  A slot holds any number
+// of items, so every item dropped by a bug can be picked up.
+func (g *Ground) insertItem(now time.Time, i int, item *Item) {
+	items := g.slots[i]
+
+	var k int
+	for j := 0; j < len(items); j++ {
+		if items[j].expired(now) {
+			continue
+		}
+		items[k] = items[j]
+		if j > k {
+			items[j] = nil
+		}
+		k++
+	}
+	items = items[:k]
+	items = append(items, item)
+	log.Printf("inserted")
+
+	g.slots[i] = items
+}
@@ -0,0 +1,196 @@
+package crunch
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"image"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Hash returns a hash of the state of the game: the board, the player, the
+// clocks and rates that drive spawning, and the random number generator.  Two
+// games given the same config and the same input hash the same after every
+// tick, so comparing hashes detects games that have fallen out of sync.
+func (g *Game) Hash() uint64 {
+	h := &boardHash{Hash64: fnv.New64a()}
+	h.int(g.ticks)
+	h.time(g.now)
+	h.int(int64(g.lag))
+	h.int(g.rand.n)
+	h.int(g.score)
+	h.int(g.scoreThreshold)
+	h.int(int64(g.skillLevel))
+	h.float(g.scoreMultiplier)
+	h.int(int64(g.combo))
+	h.time(g.comboExpires)
+	h.float(g.feedMultiplier)
+	h.time(g.feedExpires)
+	h.int(int64(g.chainSize))
+	h.point(g.chainEnd)
+	h.int(int64(g.moves))
+	h.bool(g.dying)
+	h.bool(g.killed)
+	h.bool(g.over)
+	h.bool(g.won)
+	h.int(int64(g.garbage.Rocks))
+	h.int(int64(g.garbage.Rows))
+
+	h.bool(g.bugSpawnInit)
+	h.int(int64(g.bugSpawnInitRem))
+	h.int(int64(g.bugSpawnInitDelay))
+	h.float(g.bugRate)
+	h.time(g.bugSpawnTime)
+	h.time(g.bugSpawnLast)
+	h.time(g.bugSpawnContinue)
+	h.time(g.bugSpawnStompTime)
+	h.int(int64(g.bugSpawnStompQueue))
+	h.float(g.itemSpawnRate)
+	h.float(g.itemDespawnRate)
+	h.time(g.itemSpawnTime)
+
+	h.int(int64(len(g.pendingItems)))
+	for _, item := range g.pendingItems {
+		h.int(int64(item.Type))
+		h.int(int64(item.Col))
+	}
+	for _, pending := range [][]image.Point{g.pendingExplos, g.pendingChains, g.pendingMagics, g.pendingFeeds} {
+		h.int(int64(len(pending)))
+		for _, pt := range pending {
+			h.point(pt)
+		}
+	}
+	h.time(g.resolveNext)
+	h.int(int64(len(g.multis)))
+	for _, bug := range g.multis {
+		h.bug(bug)
+	}
+	h.time(g.multisTime)
+
+	for i := range g.vines {
+		h.int(int64(len(g.vines[i])))
+		for _, bug := range g.vines[i] {
+			h.bug(bug)
+		}
+	}
+	for _, bug := range g.next {
+		h.bool(bug != nil)
+		if bug != nil {
+			h.bug(bug)
+		}
+	}
+	for i := range g.ground.slots {
+		h.int(int64(len(g.ground.slots[i])))
+		for _, item := range g.ground.slots[i] {
+			h.item(item)
+		}
+		h.int(int64(len(g.ground.landed[i])))
+		for _, landed := range g.ground.landed[i] {
+			h.bug(landed.Bug)
+			h.time(landed.Time)
+		}
+	}
+
+	p := g.player
+	h.int(int64(p.pos))
+	h.bool(p.stomping)
+	h.time(p.stompAvailable)
+	h.time(p.immobilized)
+	h.time(p.poisoned)
+	h.time(p.stunned)
+	h.int(int64(p.meter))
+	h.bool(p.contains != nil)
+	if p.contains != nil {
+		h.bug(p.contains)
+	}
+	h.int(int64(len(p.itemInv)))
+	for _, inv := range p.itemInv {
+		h.int(int64(inv.Type))
+		h.int(int64(inv.Quant))
+	}
+	return h.Sum64()
+}
+
+// countingRand counts the numbers drawn from a PRNG.  Games with the same seed
+// that have drawn the same count of numbers have the same random state.
+type countingRand struct {
+	r *rand.Rand
+	n int64
+}
+
+func newCountingRand(seed int64) *countingRand {
+	return &countingRand{r: rand.New(rand.NewSource(seed))}
+}
+
+func (r *countingRand) Intn(n int) int {
+	r.n++
+	return r.r.Intn(n)
+}
+
+func (r *countingRand) Float64() float64 {
+	r.n++
+	return r.r.Float64()
+}
+
+func (r *countingRand) NormFloat64() float64 {
+	r.n++
+	return r.r.NormFloat64()
+}
+
+// boardHash writes the fields of a Game to a hash.
+type boardHash struct {
+	hash.Hash64
+	buf [8]byte
+}
+
+func (h *boardHash) int(n int64) {
+	binary.LittleEndian.PutUint64(h.buf[:], uint64(n))
+	h.Write(h.buf[:])
+}
+
+func (h *boardHash) float(x float64) {
+	h.int(int64(math.Float64bits(x)))
+}
+
+func (h *boardHash) point(pt image.Point) {
+	h.int(int64(pt.X))
+	h.int(int64(pt.Y))
+}
+
+func (h *boardHash) bool(b bool) {
+	if b {
+		h.int(1)
+	} else {
+		h.int(0)
+	}
+}
+
+// time writes t as an offset from the zero time, at which the game clock
+// starts.
+func (h *boardHash) time(t time.Time) {
+	h.int(int64(t.Sub(time.Time{})))
+}
+
+func (h *boardHash) bug(b *Bug) {
+	h.int(int64(b.Type))
+	h.int(int64(b.Color))
+	h.int(int64(b.RColor))
+	h.int(int64(b.EColor))
+	h.int(int64(b.Eaten))
+	h.bool(b.Exploded)
+	h.int(int64(b.Rune))
+	h.int(int64(len(b.Items)))
+	for _, item := range b.Items {
+		h.item(item)
+	}
+}
+
+func (h *boardHash) item(item *Item) {
+	h.bool(item != nil)
+	if item != nil {
+		h.int(int64(item.Type))
+		h.time(item.Despawn)
+	}
+}
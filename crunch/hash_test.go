@@ -0,0 +1,37 @@
+package crunch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHashState(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		change func(g *Game)
+	}{
+		{"poisoned", func(g *Game) { g.player.poisoned = g.now.Add(time.Second) }},
+		{"stunned", func(g *Game) { g.player.stunned = g.now.Add(time.Second) }},
+		{"stomp", func(g *Game) { g.player.stompAvailable = g.now.Add(time.Second) }},
+		{"combo", func(g *Game) { g.combo++ }},
+		{"multiplier", func(g *Game) { g.scoreMultiplier = 1.5 }},
+		{"spawn", func(g *Game) { g.bugSpawnTime = g.bugSpawnTime.Add(TickDuration) }},
+		{"lag", func(g *Game) { g.lag++ }},
+		{"rand", func(g *Game) { g.rand.Intn(2) }},
+		{"rune", func(g *Game) { g.vines[0][0].Rune++ }},
+		{"pending", func(g *Game) { g.pendingItems = append(g.pendingItems, PendingItem{ItemBullet, 0}) }},
+	} {
+		g := newTestGame(t, 1)
+		for len(g.vines[0]) == 0 {
+			g.Step(nil, TickDuration)
+		}
+		before := g.Hash()
+		if g.Hash() != before {
+			t.Fatal("hash is not stable")
+		}
+		test.change(g)
+		if g.Hash() == before {
+			t.Errorf("%s: hash unchanged", test.name)
+		}
+	}
+}
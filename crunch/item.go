@@ -0,0 +1,60 @@
+//go:generate stringer -type=ItemType
+
+package crunch
+
+import "time"
+
+// PendingItem is an items that was fired from a specific column.
+type PendingItem struct {
+	Type ItemType
+	Col  int
+}
+
+// Item is a useful item for the player.  Special items spawn on/in bugs, in
+// which case the Despawn time respresents the time until the item is
+// "digested" and disappears.  An item dropped by a bug keeps its Despawn time
+// on the ground.  Items with a zero Despawn time never disappear.
+type Item struct {
+	Type    ItemType
+	Despawn time.Time
+}
+
+func (item *Item) expired(now time.Time) bool {
+	return !item.Despawn.IsZero() && now.After(item.Despawn)
+}
+
+// ItemType is a classification of item that can picked up off the ground.
+type ItemType uint
+
+// ItemType constants
+const (
+	ItemMoneyXXS ItemType = iota
+	ItemMoneyXS
+	ItemMoneySM
+	ItemMoneyMD
+	ItemMoneyLG
+	ItemMoneyXL
+	ItemMoneyXXL
+	ItemPoison
+	ItemRowClear
+	ItemPushUp
+	ItemBullet
+	ItemScramble
+	ItemRecolor
+	itemMax = iota - 1
+)
+
+// IsMoney returns true if item is a money type
+func (item ItemType) IsMoney() bool {
+	return item <= ItemMoneyXL
+}
+
+// IsPoison returns true if item is a poison type
+func (item ItemType) IsPoison() bool {
+	return item == ItemPoison
+}
+
+// IsSpecial returns true if item is a special item.
+func (item ItemType) IsSpecial() bool {
+	return item >= ItemRowClear
+}
@@ -0,0 +1,147 @@
+package crunch
+
+import (
+	"log"
+	"time"
+)
+
+// PlayerControl is an abstract representation of a key or mouse button press.
+// This allows controls to be remapped to different keys with user
+// configuration.
+type PlayerControl uint8
+
+// PlayerControl constants
+const (
+	PlayerMoveLeft PlayerControl = iota
+	PlayerMoveRight
+	PlayerGrabSpit
+	PlayerStomp
+	PlayerPuke
+	PlayerItemUse
+	PlayerItemForward
+	PlayerItemBackward
+)
+
+// Inv represents an item in the player's inventory.  Multiple copies of the
+// same item may be held at a time.
+type Inv struct {
+	Type  ItemType
+	Quant int
+}
+
+// Player is a player in a Game
+type Player struct {
+	pos            int
+	stomping       bool
+	stompAvailable time.Time
+	immobilized    time.Time
+	poisoned       time.Time
+	stunned        time.Time
+	itemInv        []*Inv
+	contains       *Bug
+	meter          int
+}
+
+func newPlayer(pos int) *Player {
+	return &Player{pos: pos}
+}
+
+// Pos returns the column the player is standing under.  The player may stand
+// one column to the right of the last vine.
+func (p *Player) Pos() int {
+	return p.pos
+}
+
+// Contains returns the bug held by the player or nil if the player is not
+// holding a bug.
+func (p *Player) Contains() *Bug {
+	return p.contains
+}
+
+// Meter returns the number of crunched bugs the player has stored to feed
+// their young, at most PukeMeterMax.
+func (p *Player) Meter() int {
+	return p.meter
+}
+
+func (p *Player) fillMeter(n int) {
+	p.meter += n
+	if p.meter > PukeMeterMax {
+		p.meter = PukeMeterMax
+	}
+}
+
+// Stomping returns true if the player is stomping.
+func (p *Player) Stomping() bool {
+	return p.stomping
+}
+
+// Inventory returns the special items held by the player.  The first item is
+// the one that will be used next.  The returned slice must not be modified.
+func (p *Player) Inventory() []*Inv {
+	return p.itemInv
+}
+
+func (p *Player) addInv(typ ItemType) {
+	for i := range p.itemInv {
+		if p.itemInv[i].Type == typ {
+			p.itemInv[i].Quant++
+			return
+		}
+	}
+	p.itemInv = append(p.itemInv, &Inv{
+		Type:  typ,
+		Quant: 1,
+	})
+
+	for i := range p.itemInv {
+		log.Printf("ipos=%d item=%v quant=%d", i, p.itemInv[i].Type, p.itemInv[i].Quant)
+	}
+}
+
+func (p *Player) useInv() (typ ItemType, ok bool) {
+	if len(p.itemInv) == 0 {
+		return 0, false
+	}
+	typ = p.itemInv[0].Type
+	p.itemInv[0].Quant--
+	if p.itemInv[0].Quant == 0 {
+		copy(p.itemInv, p.itemInv[1:])
+		p.itemInv[len(p.itemInv)-1] = nil
+		p.itemInv = p.itemInv[:len(p.itemInv)-1]
+	}
+	return typ, true
+}
+
+func (p *Player) rotateInv(i int) {
+	if len(p.itemInv) == 0 {
+		return
+	}
+
+	i = i % len(p.itemInv)
+	rotated := make([]*Inv, 0, cap(p.itemInv))
+	if i > 0 {
+		rotated = append(rotated, p.itemInv[len(p.itemInv)-i:]...)
+		rotated = append(rotated, p.itemInv[:len(p.itemInv)-i]...)
+	} else {
+		rotated = append(rotated, p.itemInv[-i:]...)
+		rotated = append(rotated, p.itemInv[:-i]...)
+	}
+	p.itemInv = rotated
+
+}
+
+func (p *Player) clearStomp(now time.Time) {
+	p.stomping = false
+}
+
+func (p *Player) beginStomp(now time.Time) bool {
+	if !now.After(p.stompAvailable) {
+		return false
+	}
+	log.Printf("stomping")
+	p.stomping = true
+	p.immobilized = now.Add(StompTime)
+	p.stompAvailable = now.Add(StompTime + StompRest)
+	return true
+}
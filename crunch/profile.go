@@ -0,0 +1,392 @@
+package crunch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Profile is a SurvivalDifficulty described by a table of levels.  Profiles
+// are stored as json.  Bugs, colors, and items are weighted by name, and
+// colors are the indices used by PuzzleBug.  Rates and times are in seconds.
+//
+//	{
+//		"Name": "normal",
+//		"InitBugs": 12,
+//		"InitRate": 0.3,
+//		"LevelOne": 30,
+//		"LevelBase": 1.5,
+//		"Levels": [
+//			{
+//				"Level": 1,
+//				"BugRate": 6.93,
+//				"ItemRate": 13.5,
+//				"ItemLife": 9.6,
+//				"Bugs": {"Small": 500, "Large": 400, "Gnat": 200},
+//				"Colors": {"Small": {"0": 1}, "Large": {"2": 1}},
+//				"Items": {"RowClear": 10, "Bullet": 10},
+//				"Poison": {"RockRate": 0.5, "GnatRate": 0.06, "Immobilize": 1, "Score": 25, "Multiplier": 0.5},
+//				"Hazard": {"Stun": 1.5, "Damage": 50}
+//			},
+//			{"Level": 3, "BugRate": 6.79, "ItemRate": 10.9, "ItemLife": 8.8, "Bugs": {"Small": 390, "Large": 385, "Gnat": 195, "Bomb": 30}}
+//		]
+//	}
+//
+// Levels between two entries are interpolated and levels after the last
+// entry are played like the last entry.  An entry which omits a rate or a
+// table copies it from the entry before it, so consecutive entries may be
+// used to change a table at a specific level.
+type Profile struct {
+	Name      string
+	InitBugs  int     // bugs spawned one at a time to begin a game
+	InitRate  float64 // seconds between the bugs spawned to begin a game
+	LevelOne  int64   // score needed to reach the first level
+	LevelBase float64 // growth of the score needed for each level
+	Levels    []*ProfileLevel
+}
+
+var _ SurvivalDifficulty = &Profile{}
+
+// ProfileLevel is an entry in the table of a Profile.
+type ProfileLevel struct {
+	Level    int
+	BugRate  float64
+	ItemRate float64
+	ItemLife float64
+	Bugs     map[BugType]int         `json:",omitempty"`
+	Colors   map[BugType]map[int]int `json:",omitempty"`
+	Items    map[ItemType]int        `json:",omitempty"`
+	Poison   *ProfilePoison          `json:",omitempty"`
+	Hazard   *ProfileHazard          `json:",omitempty"`
+}
+
+// ProfilePoison is the json form of PoisonRules.
+type ProfilePoison struct {
+	RockRate   float64
+	GnatRate   float64
+	Immobilize float64
+	Score      float64
+	Multiplier float64
+}
+
+// ProfileHazard is the json form of HazardRules.
+type ProfileHazard struct {
+	Stun   float64
+	Damage float64
+	Lethal bool `json:",omitempty"`
+}
+
+// ReadProfile reads a profile from r, fills in the values its entries omit,
+// and validates it.
+func ReadProfile(r io.Reader) (*Profile, error) {
+	var p *Profile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&p)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, fmt.Errorf("no profile defined")
+	}
+	p.inherit()
+	err = p.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// inherit copies the rates and tables omitted from each entry from the entry
+// before it.
+func (p *Profile) inherit() {
+	for i := 1; i < len(p.Levels); i++ {
+		prev, lvl := p.Levels[i-1], p.Levels[i]
+		if lvl.BugRate == 0 {
+			lvl.BugRate = prev.BugRate
+		}
+		if lvl.ItemRate == 0 {
+			lvl.ItemRate = prev.ItemRate
+		}
+		if lvl.ItemLife == 0 {
+			lvl.ItemLife = prev.ItemLife
+		}
+		if lvl.Bugs == nil {
+			lvl.Bugs = prev.Bugs
+		}
+		if lvl.Colors == nil {
+			lvl.Colors = prev.Colors
+		}
+		if lvl.Items == nil {
+			lvl.Items = prev.Items
+		}
+		if lvl.Poison == nil {
+			lvl.Poison = prev.Poison
+		}
+		if lvl.Hazard == nil {
+			lvl.Hazard = prev.Hazard
+		}
+	}
+}
+
+// Validate returns an error if p cannot be played.
+func (p *Profile) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("profile has no name")
+	}
+	if p.InitBugs < 0 {
+		return fmt.Errorf("profile %s: negative InitBugs", p.Name)
+	}
+	if p.InitRate <= 0 {
+		return fmt.Errorf("profile %s: InitRate must be positive", p.Name)
+	}
+	if p.LevelOne <= 0 {
+		return fmt.Errorf("profile %s: LevelOne must be positive", p.Name)
+	}
+	if p.LevelBase < 1 {
+		return fmt.Errorf("profile %s: LevelBase must be at least 1", p.Name)
+	}
+	if len(p.Levels) == 0 {
+		return fmt.Errorf("profile %s: no levels", p.Name)
+	}
+	for i, lvl := range p.Levels {
+		if i > 0 && lvl.Level <= p.Levels[i-1].Level {
+			return fmt.Errorf("profile %s: level %d is out of order", p.Name, lvl.Level)
+		}
+		err := lvl.validate()
+		if err != nil {
+			return fmt.Errorf("profile %s: level %d: %v", p.Name, lvl.Level, err)
+		}
+	}
+	return nil
+}
+
+func (lvl *ProfileLevel) validate() error {
+	if lvl.BugRate <= 0 || lvl.ItemRate <= 0 || lvl.ItemLife <= 0 {
+		return fmt.Errorf("rates must be positive")
+	}
+	if weightTotal(bugWeights(lvl.Bugs)) <= 0 {
+		return fmt.Errorf("no bugs")
+	}
+	for typ, n := range lvl.Bugs {
+		if n < 0 {
+			return fmt.Errorf("negative weight for %v", typ)
+		}
+	}
+	for typ, colors := range lvl.Colors {
+		if typ != BugSmall && typ != BugLarge {
+			return fmt.Errorf("%v cannot be given colors", typ)
+		}
+		for c, n := range colors {
+			if !bugHasColor(typ, ColorBug+Color(c)) {
+				return fmt.Errorf("%v cannot have color %d", typ, c)
+			}
+			if n < 0 {
+				return fmt.Errorf("negative weight for %v color %d", typ, c)
+			}
+		}
+	}
+	for _, typ := range []BugType{BugSmall, BugLarge} {
+		if lvl.Bugs[typ] > 0 && weightTotal(lvl.Colors[typ]) <= 0 {
+			return fmt.Errorf("no colors for %v", typ)
+		}
+	}
+	if weightTotal(itemWeights(lvl.Items)) <= 0 {
+		return fmt.Errorf("no items")
+	}
+	for typ, n := range lvl.Items {
+		if !typ.IsSpecial() {
+			return fmt.Errorf("%v cannot spawn on bugs", typ)
+		}
+		if n < 0 {
+			return fmt.Errorf("negative weight for %v", typ)
+		}
+	}
+	if lvl.Poison == nil {
+		return fmt.Errorf("no poison rules")
+	}
+	if !isProbability(lvl.Poison.RockRate) || !isProbability(lvl.Poison.GnatRate) || !isProbability(lvl.Poison.Multiplier) {
+		return fmt.Errorf("poison rates and multiplier must be between 0 and 1")
+	}
+	if lvl.Poison.Immobilize < 0 || lvl.Poison.Score < 0 {
+		return fmt.Errorf("negative poison penalty")
+	}
+	if lvl.Hazard == nil {
+		return fmt.Errorf("no hazard rules")
+	}
+	if lvl.Hazard.Stun < 0 || lvl.Hazard.Damage < 0 {
+		return fmt.Errorf("negative hazard penalty")
+	}
+	return nil
+}
+
+func bugHasColor(typ BugType, c Color) bool {
+	for _, color := range bugColors[typ] {
+		if color == c {
+			return true
+		}
+	}
+	return false
+}
+
+// numColors returns the number of colors bugs may have, including the colors
+// with special significance.
+func numColors() int {
+	var n Color
+	for _, colors := range bugColors {
+		for _, c := range colors {
+			if c >= n {
+				n = c + 1
+			}
+		}
+	}
+	return int(n)
+}
+
+func isProbability(x float64) bool {
+	return x >= 0 && x <= 1
+}
+
+func weightTotal(weights map[int]int) int {
+	var n int
+	for _, w := range weights {
+		n += w
+	}
+	return n
+}
+
+// span returns the entries surrounding lvl and how far lvl is between them.
+func (p *Profile) span(lvl int) (a, b *ProfileLevel, t float64) {
+	if lvl <= p.Levels[0].Level {
+		return p.Levels[0], p.Levels[0], 0
+	}
+	for i := 1; i < len(p.Levels); i++ {
+		if lvl < p.Levels[i].Level {
+			a, b = p.Levels[i-1], p.Levels[i]
+			return a, b, float64(lvl-a.Level) / float64(b.Level-a.Level)
+		}
+	}
+	last := p.Levels[len(p.Levels)-1]
+	return last, last, 0
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + t*(b-a)
+}
+
+func lerpDuration(a, b, t float64) time.Duration {
+	return time.Duration(lerp(a, b, t) * float64(time.Second))
+}
+
+// lerpWeights returns a distribution over n values with weights
+// interpolated between a and b.  If rounding leaves no weight the weights of
+// a are used.
+func lerpWeights(n int, a, b map[int]int, t float64) intDistn {
+	d := make(intDistn, n)
+	var total int
+	for i := range d {
+		d[i] = int(math.Round(lerp(float64(a[i]), float64(b[i]), t)))
+		total += d[i]
+	}
+	if total > 0 {
+		return d
+	}
+	for i := range d {
+		d[i] = a[i]
+	}
+	return d
+}
+
+func bugWeights(m map[BugType]int) map[int]int {
+	w := make(map[int]int, len(m))
+	for typ, n := range m {
+		w[int(typ)] = n
+	}
+	return w
+}
+
+func colorWeights(m map[int]int) map[int]int {
+	w := make(map[int]int, len(m))
+	for c, n := range m {
+		w[int(ColorBug)+c] = n
+	}
+	return w
+}
+
+func itemWeights(m map[ItemType]int) map[int]int {
+	w := make(map[int]int, len(m))
+	for typ, n := range m {
+		w[int(typ)] = n
+	}
+	return w
+}
+
+// NextLevel implements SurvivalDifficulty.
+func (p *Profile) NextLevel(lvl int) int64 {
+	return int64(float64(p.LevelOne) * math.Pow(p.LevelBase, float64(lvl)))
+}
+
+// NumBugInit implements SurvivalDifficulty.
+func (p *Profile) NumBugInit() int {
+	return p.InitBugs
+}
+
+// BugRateInit implements SurvivalDifficulty.
+func (p *Profile) BugRateInit() float64 {
+	return p.InitRate
+}
+
+// BugRate implements SurvivalDifficulty.
+func (p *Profile) BugRate(lvl int) float64 {
+	a, b, t := p.span(lvl)
+	return lerp(a.BugRate, b.BugRate, t)
+}
+
+// ItemRate implements SurvivalDifficulty.
+func (p *Profile) ItemRate(lvl int) (spawn, despawn float64) {
+	a, b, t := p.span(lvl)
+	return lerp(a.ItemRate, b.ItemRate, t), lerp(a.ItemLife, b.ItemLife, t)
+}
+
+// BugDistribution implements SurvivalDifficulty.
+func (p *Profile) BugDistribution(lvl int) BugDistribution {
+	a, b, t := p.span(lvl)
+	types := bugTypeDistn(lerpWeights(int(bugNumType), bugWeights(a.Bugs), bugWeights(b.Bugs), t))
+	colors := make(bugColorCondDistn, bugNumType)
+	for _, typ := range []BugType{BugSmall, BugLarge} {
+		colors[typ] = bugColorDistn(lerpWeights(numColors(), colorWeights(a.Colors[typ]), colorWeights(b.Colors[typ]), t))
+	}
+	return &simpleDistribution{&types, &colors}
+}
+
+// ItemDistribution implements SurvivalDifficulty.
+func (p *Profile) ItemDistribution(lvl int) ItemDistribution {
+	a, b, t := p.span(lvl)
+	return itemTypeDistn(lerpWeights(int(itemMax)+1, itemWeights(a.Items), itemWeights(b.Items), t))
+}
+
+// Poison implements SurvivalDifficulty.
+func (p *Profile) Poison(lvl int) PoisonRules {
+	a, b, t := p.span(lvl)
+	pa, pb := a.Poison, b.Poison
+	return PoisonRules{
+		RockRate:   lerp(pa.RockRate, pb.RockRate, t),
+		GnatRate:   lerp(pa.GnatRate, pb.GnatRate, t),
+		Immobilize: lerpDuration(pa.Immobilize, pb.Immobilize, t),
+		Score:      int64(math.Round(lerp(pa.Score, pb.Score, t))),
+		Multiplier: lerp(pa.Multiplier, pb.Multiplier, t),
+	}
+}
+
+// Hazard implements SurvivalDifficulty.
+func (p *Profile) Hazard(lvl int) HazardRules {
+	a, b, t := p.span(lvl)
+	ha, hb := a.Hazard, b.Hazard
+	return HazardRules{
+		Stun:   lerpDuration(ha.Stun, hb.Stun, t),
+		Damage: int64(math.Round(lerp(ha.Damage, hb.Damage, t))),
+		Lethal: ha.Lethal,
+	}
+}
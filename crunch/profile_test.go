@@ -0,0 +1,281 @@
+package crunch
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// baselineLevel is a level of the survival difficulty that was built into
+// the game before profiles, which the normal profile reproduces.
+type baselineLevel struct {
+	bugRate, itemRate, itemLife float64
+	bugs                        bugTypeDistn
+	colors                      bugColorCondDistn
+	gnatRate                    float64
+}
+
+func baseline(lvl int) baselineLevel {
+	b := baselineLevel{
+		bugRate:  7 * math.Pow(0.99, float64(lvl)),
+		itemRate: 15 * math.Pow(0.90, float64(lvl)),
+		itemLife: 10 * math.Pow(0.96, float64(lvl)),
+		gnatRate: math.Min(0.05+0.01*float64(lvl), 0.2),
+	}
+	two := func(a, b int) bugColorDistn {
+		d := make(bugColorDistn, numColors())
+		d[ColorBug+0], d[ColorBug+1] = a, b
+		return d
+	}
+	large := func(a, b int) bugColorDistn {
+		d := make(bugColorDistn, numColors())
+		d[ColorBug+2], d[ColorBug+3] = a, b
+		return d
+	}
+	bugs := func(w ...int) bugTypeDistn {
+		d := make(bugTypeDistn, bugNumType)
+		copy(d, w)
+		return d
+	}
+	b.colors = make(bugColorCondDistn, bugNumType)
+	b.colors[BugSmall], b.colors[BugLarge] = two(1, 1), large(1, 1)
+	switch {
+	case lvl < 3:
+		b.colors[BugSmall] = two(1, 0)
+		b.colors[BugLarge] = large(1, 0)
+		b.bugs = bugs(500, 400, 200, 0, 0, 0, 0, 0)
+	case lvl < 5:
+		b.colors[BugLarge] = large(1, 0)
+		b.bugs = bugs(390, 385, 195, 0, 30, 0, 0, 0)
+	case lvl == 6:
+		b.bugs = bugs(380, 375, 192, 0, 15, 15, 10, 10)
+	case lvl == 7:
+		b.bugs = bugs(373, 363, 190, 10, 15, 15, 15, 10)
+	case lvl == 8:
+		b.bugs = bugs(378, 358, 180, 15, 15, 15, 15, 15)
+	case lvl == 9:
+		b.bugs = bugs(380, 350, 170, 20, 20, 20, 20, 20)
+	case lvl == 10:
+		b.bugs = bugs(383, 343, 160, 25, 25, 25, 25, 25)
+	default:
+		b.bugs = bugs(390, 340, 150, 30, 30, 30, 30, 30)
+	}
+	return b
+}
+
+func closeTo(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol*math.Abs(b)
+}
+
+func TestProfileNormalBaseline(t *testing.T) {
+	p := readTestProfile(t, "normal")
+	for lvl := 1; lvl <= 30; lvl++ {
+		b := baseline(lvl)
+		// The profile lists rates to two decimals and interpolates the
+		// levels between its entries.
+		const tol = 0.01
+		if r := p.BugRate(lvl); !closeTo(r, b.bugRate, tol) {
+			t.Errorf("level %d: BugRate %.3f (baseline %.3f)", lvl, r, b.bugRate)
+		}
+		spawn, despawn := p.ItemRate(lvl)
+		if !closeTo(spawn, b.itemRate, tol) || !closeTo(despawn, b.itemLife, tol) {
+			t.Errorf("level %d: ItemRate %.3f %.3f (baseline %.3f %.3f)",
+				lvl, spawn, despawn, b.itemRate, b.itemLife)
+		}
+		if r := p.Poison(lvl).GnatRate; !closeTo(r, b.gnatRate, 1e-9) {
+			t.Errorf("level %d: GnatRate %.3f (baseline %.3f)", lvl, r, b.gnatRate)
+		}
+		poison := p.Poison(lvl)
+		if poison.RockRate != 0.5 || poison.Immobilize != time.Second || poison.Score != 25 || poison.Multiplier != 0.5 {
+			t.Errorf("level %d: poison %+v", lvl, poison)
+		}
+		hazard := p.Hazard(lvl)
+		if hazard != (HazardRules{Stun: 1500 * time.Millisecond, Damage: 50}) {
+			t.Errorf("level %d: hazard %+v", lvl, hazard)
+		}
+		items := make(itemTypeDistn, itemMax+1)
+		for _, typ := range []ItemType{ItemRowClear, ItemPushUp, ItemBullet, ItemScramble, ItemRecolor} {
+			items[typ] = 10
+		}
+		if d := p.ItemDistribution(lvl); !reflect.DeepEqual(d, items) {
+			t.Errorf("level %d: items %v", lvl, d)
+		}
+
+		d := p.BugDistribution(lvl).(*simpleDistribution)
+		if lvl == 5 {
+			// The baseline skipped from the level 3 bugs to the bugs of
+			// level 11 and back to level 6.  The profile blends levels 4
+			// and 6 instead.
+			continue
+		}
+		if !reflect.DeepEqual(*d.bugTypeDistn, b.bugs) {
+			t.Errorf("level %d: bugs %v (baseline %v)", lvl, *d.bugTypeDistn, b.bugs)
+		}
+		for _, typ := range []BugType{BugSmall, BugLarge} {
+			if !reflect.DeepEqual((*d.bugColorCondDistn)[typ], b.colors[typ]) {
+				t.Errorf("level %d: %v colors %v (baseline %v)",
+					lvl, typ, (*d.bugColorCondDistn)[typ], b.colors[typ])
+			}
+		}
+	}
+	for lvl := 0; lvl <= 30; lvl++ {
+		if p.NextLevel(lvl) != int64(30*math.Pow(1.5, float64(lvl))) {
+			t.Errorf("level %d: NextLevel %d", lvl, p.NextLevel(lvl))
+		}
+	}
+	if p.NumBugInit() != 12 || p.BugRateInit() != 0.3 {
+		t.Errorf("initial bugs %d at %v", p.NumBugInit(), p.BugRateInit())
+	}
+}
+
+// testProfile is a valid profile with entries at levels 4 and 6.
+const testProfile = `{
+	"Name": "test",
+	"InitBugs": 12,
+	"InitRate": 0.3,
+	"LevelOne": 30,
+	"LevelBase": 1.5,
+	"Levels": [
+		{
+			"Level": 4,
+			"BugRate": 6,
+			"ItemRate": 10,
+			"ItemLife": 8,
+			"Bugs": {"Small": 400, "Large": 400, "Bomb": 30},
+			"Colors": {"Small": {"0": 1}, "Large": {"2": 1}},
+			"Items": {"RowClear": 10},
+			"Poison": {"RockRate": 0.5, "GnatRate": 0.1, "Immobilize": 1, "Score": 20, "Multiplier": 0.5},
+			"Hazard": {"Stun": 1, "Damage": 50}
+		},
+		{
+			"Level": 6,
+			"BugRate": 5,
+			"Bugs": {"Small": 300, "Large": 400, "Rock": 10},
+			"Colors": {"Small": {"0": 1, "1": 1}, "Large": {"2": 1}},
+			"Poison": {"RockRate": 0.5, "GnatRate": 0.2, "Immobilize": 2, "Score": 30, "Multiplier": 0.5}
+		}
+	]
+}`
+
+func readString(s string) (*Profile, error) {
+	return ReadProfile(strings.NewReader(s))
+}
+
+func TestProfileInherit(t *testing.T) {
+	p, err := readString(testProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := p.Levels[0], p.Levels[1]
+	if b.ItemRate != 10 || b.ItemLife != 8 {
+		t.Errorf("rates not inherited: %v %v", b.ItemRate, b.ItemLife)
+	}
+	if b.BugRate != 5 || b.Bugs[BugSmall] != 300 {
+		t.Errorf("rates overwritten: %v %v", b.BugRate, b.Bugs)
+	}
+	if !reflect.DeepEqual(b.Items, a.Items) || b.Hazard != a.Hazard {
+		t.Errorf("tables not inherited: %v %v", b.Items, b.Hazard)
+	}
+}
+
+func TestProfileSpan(t *testing.T) {
+	p, err := readString(testProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		lvl  int
+		a, b int
+		t    float64
+	}{
+		{1, 4, 4, 0},
+		{4, 4, 4, 0},
+		{5, 4, 6, 0.5},
+		{6, 6, 6, 0},
+		{20, 6, 6, 0},
+	} {
+		a, b, x := p.span(test.lvl)
+		if a.Level != test.a || b.Level != test.b || x != test.t {
+			t.Errorf("level %d: span %d %d %v (expected %d %d %v)",
+				test.lvl, a.Level, b.Level, x, test.a, test.b, test.t)
+		}
+	}
+}
+
+func TestProfileGap(t *testing.T) {
+	p, err := readString(testProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := p.BugRate(5); r != 5.5 {
+		t.Errorf("BugRate %v", r)
+	}
+	if spawn, despawn := p.ItemRate(5); spawn != 10 || despawn != 8 {
+		t.Errorf("ItemRate %v %v", spawn, despawn)
+	}
+	poison := p.Poison(5)
+	if !closeTo(poison.GnatRate, 0.15, 1e-9) || poison.Immobilize != 1500*time.Millisecond || poison.Score != 25 {
+		t.Errorf("poison %+v", poison)
+	}
+	d := p.BugDistribution(5).(*simpleDistribution)
+	bugs := make(bugTypeDistn, bugNumType)
+	bugs[BugSmall], bugs[BugLarge], bugs[BugBomb], bugs[BugRock] = 350, 400, 15, 5
+	if !reflect.DeepEqual(*d.bugTypeDistn, bugs) {
+		t.Errorf("bugs %v (expected %v)", *d.bugTypeDistn, bugs)
+	}
+	small := (*d.bugColorCondDistn)[BugSmall]
+	if small[ColorBug+0] != 1 || small[ColorBug+1] != 1 {
+		t.Errorf("small colors %v", small)
+	}
+}
+
+func TestLerpWeights(t *testing.T) {
+	for _, test := range []struct {
+		a, b   map[int]int
+		t      float64
+		expect intDistn
+	}{
+		{map[int]int{0: 10}, map[int]int{0: 20}, 0, intDistn{10, 0, 0}},
+		{map[int]int{0: 10}, map[int]int{0: 20}, 1, intDistn{20, 0, 0}},
+		{map[int]int{0: 10}, map[int]int{1: 20}, 0.5, intDistn{5, 10, 0}},
+		{map[int]int{0: 10, 2: 3}, map[int]int{0: 10}, 0.25, intDistn{10, 0, 2}},
+
+		// Rounding away every weight falls back to the first entry.
+		{map[int]int{0: 1}, map[int]int{}, 0.6, intDistn{1, 0, 0}},
+	} {
+		d := lerpWeights(3, test.a, test.b, test.t)
+		if !reflect.DeepEqual(d, test.expect) {
+			t.Errorf("%v %v %v: %v (expected %v)", test.a, test.b, test.t, d, test.expect)
+		}
+	}
+}
+
+func TestReadProfileInvalid(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		old, new  string
+		errSubstr string
+	}{
+		{"unknown field", `"InitBugs"`, `"Bugz": 1, "InitBugs"`, "unknown field"},
+		{"unknown level field", `"BugRate": 5,`, `"BugRate": 5, "Speed": 2,`, "unknown field"},
+		{"unknown bug", `"Rock": 10`, `"Ant": 10`, "Ant"},
+		{"out of order", `"Level": 6`, `"Level": 4`, "out of order"},
+
+		// The first entry has no entry to inherit from.
+		{"nothing to inherit", `"ItemLife": 8,`, ``, "rates must be positive"},
+		{"first entry first", `"Level": 6`, `"Level": 2`, "out of order"},
+	} {
+		s := strings.Replace(testProfile, test.old, test.new, 1)
+		if s == testProfile {
+			t.Fatalf("%s: profile unchanged", test.name)
+		}
+		_, err := readString(s)
+		if err == nil {
+			t.Errorf("%s: profile accepted", test.name)
+		} else if !strings.Contains(err.Error(), test.errSubstr) {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+}
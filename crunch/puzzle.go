@@ -0,0 +1,211 @@
+package crunch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultPuzzleDepth is the depth of the vines in a Puzzle that does not
+// specify one.
+const DefaultPuzzleDepth = 7
+
+// The largest puzzle board has MaxPuzzleVines vines which are MaxPuzzleDepth
+// bugs deep, the largest board a player may choose for survival.
+const (
+	MaxPuzzleVines = 16
+	MaxPuzzleDepth = 15
+)
+
+// Puzzle is a hand-authored starting position for a Game.  Nothing spawns
+// during a puzzle and the player has a fixed budget of moves.  A puzzle is
+// solved by clearing every bug from the vines.
+//
+// Puzzles are stored as json.  Bugs are written as strings described by
+// PuzzleBug and inventory items are keyed by name.
+//
+//	{
+//		"ID": "intro-1",
+//		"Name": "Unua Manĝo",
+//		"Moves": 2,
+//		"Vines": [["Large:2", "Small:0"], [], ["Small:0+Bullet"]],
+//		"Inventory": {"RowClear": 1}
+//	}
+type Puzzle struct {
+	ID        string
+	Name      string
+	Moves     int
+	Depth     int              `json:",omitempty"`
+	Vines     [][]PuzzleBug    // bugs on each vine from the canopy down
+	Inventory map[ItemType]int `json:",omitempty"`
+}
+
+// ReadPuzzle reads a puzzle from r and validates it.
+func ReadPuzzle(r io.Reader) (*Puzzle, error) {
+	var p *Puzzle
+	err := json.NewDecoder(r).Decode(&p)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, fmt.Errorf("no puzzle defined")
+	}
+	err = p.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ColDepth returns the depth of the puzzle's vines.
+func (p *Puzzle) ColDepth() int {
+	if p.Depth == 0 {
+		return DefaultPuzzleDepth
+	}
+	return p.Depth
+}
+
+// Validate returns an error if p cannot be played.
+func (p *Puzzle) Validate() error {
+	if p.ID == "" {
+		return fmt.Errorf("puzzle has no id")
+	}
+	if p.Moves <= 0 {
+		return fmt.Errorf("puzzle %s: move budget must be positive", p.ID)
+	}
+	if p.Depth < 0 || p.Depth > MaxPuzzleDepth {
+		return fmt.Errorf("puzzle %s: depth must be between 1 and %d: %d", p.ID, MaxPuzzleDepth, p.Depth)
+	}
+	if len(p.Vines) == 0 || len(p.Vines) > MaxPuzzleVines {
+		return fmt.Errorf("puzzle %s: must have between 1 and %d vines: %d", p.ID, MaxPuzzleVines, len(p.Vines))
+	}
+	numBug := 0
+	for i, vine := range p.Vines {
+		if len(vine) > p.ColDepth() {
+			return fmt.Errorf("puzzle %s: vine %d has more than %d bugs", p.ID, i, p.ColDepth())
+		}
+		numBug += len(vine)
+	}
+	if numBug == 0 {
+		return fmt.Errorf("puzzle %s: no bugs", p.ID)
+	}
+	for typ, n := range p.Inventory {
+		if !typ.IsSpecial() {
+			return fmt.Errorf("puzzle %s: %v cannot be held by the player", p.ID, typ)
+		}
+		if n <= 0 {
+			return fmt.Errorf("puzzle %s: non-positive quantity of %v", p.ID, typ)
+		}
+	}
+	return nil
+}
+
+// PuzzleBug is a bug placed on a vine by a Puzzle.  As text a PuzzleBug is
+// written "Type[:Color][+Item...]".  Type is a BugType without its "Bug"
+// prefix.  Color is the index of a bug color, 0 or 1 for small bugs and 2 or 3
+// for large bugs, and may be omitted for bugs which have only one color.  Each
+// Item is an ItemType without its "Item" prefix, and a bug may hold up to
+// MaxBugItems items.  For example, "Large:3+Bullet" is a large bug of the
+// fourth color holding a bullet.
+type PuzzleBug struct {
+	Type  BugType
+	Color Color
+	Items []ItemType
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (b PuzzleBug) MarshalText() ([]byte, error) {
+	text, _ := b.Type.MarshalText()
+	if len(bugColors[b.Type]) > 1 {
+		text = append(text, ':')
+		text = strconv.AppendInt(text, int64(b.Color-ColorBug), 10)
+	}
+	for _, item := range b.Items {
+		name, _ := item.MarshalText()
+		text = append(text, '+')
+		text = append(text, name...)
+	}
+	return text, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (b *PuzzleBug) UnmarshalText(text []byte) error {
+	pieces := strings.Split(string(text), "+")
+	typ, color := pieces[0], ""
+	if i := strings.Index(typ, ":"); i >= 0 {
+		typ, color = typ[:i], typ[i+1:]
+	}
+	err := b.Type.UnmarshalText([]byte(typ))
+	if err != nil {
+		return err
+	}
+	colors := bugColors[b.Type]
+	b.Color = colors[0]
+	if color != "" {
+		n, err := strconv.Atoi(color)
+		if err != nil {
+			return fmt.Errorf("invalid bug color %q", color)
+		}
+		b.Color = ColorBug + Color(n)
+		valid := false
+		for _, c := range colors {
+			valid = valid || c == b.Color
+		}
+		if !valid {
+			return fmt.Errorf("%s cannot have color %s", typ, color)
+		}
+	}
+	b.Items = nil
+	for _, name := range pieces[1:] {
+		var item ItemType
+		err := item.UnmarshalText([]byte(name))
+		if err != nil {
+			return err
+		}
+		b.Items = append(b.Items, item)
+	}
+	if len(b.Items) > MaxBugItems {
+		return fmt.Errorf("bugs may hold at most %d items: %q", MaxBugItems, text)
+	}
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t BugType) MarshalText() ([]byte, error) {
+	if t > bugMax {
+		return nil, fmt.Errorf("invalid bug type: %d", t)
+	}
+	return []byte(strings.TrimPrefix(t.String(), "Bug")), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (t *BugType) UnmarshalText(text []byte) error {
+	for typ := BugType(0); typ <= bugMax; typ++ {
+		if typ.String() == "Bug"+string(text) {
+			*t = typ
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown bug type: %q", text)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t ItemType) MarshalText() ([]byte, error) {
+	if t > itemMax {
+		return nil, fmt.Errorf("invalid item type: %d", t)
+	}
+	return []byte(strings.TrimPrefix(t.String(), "Item")), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (t *ItemType) UnmarshalText(text []byte) error {
+	for typ := ItemType(0); typ <= itemMax; typ++ {
+		if typ.String() == "Item"+string(text) {
+			*t = typ
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown item type: %q", text)
+}
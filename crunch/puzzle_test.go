@@ -0,0 +1,115 @@
+package crunch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPuzzleBugText(t *testing.T) {
+	for _, test := range []struct {
+		text  string
+		bug   PuzzleBug
+		valid bool
+	}{
+		{"Large:3+Bullet", PuzzleBug{BugLarge, ColorBug + 3, []ItemType{ItemBullet}}, true},
+		{"Small:0", PuzzleBug{BugSmall, ColorBug + 0, nil}, true},
+		{"Small:1+RowClear+Scramble+Recolor", PuzzleBug{BugSmall, ColorBug + 1, []ItemType{ItemRowClear, ItemScramble, ItemRecolor}}, true},
+		{"Gnat", PuzzleBug{BugGnat, ColorNone, nil}, true},
+		{"Bomb+PushUp", PuzzleBug{BugBomb, ColorBomb, []ItemType{ItemPushUp}}, true},
+		{"Large:0", PuzzleBug{}, false},
+		{"Small:4", PuzzleBug{}, false},
+		{"Small:red", PuzzleBug{}, false},
+		{"Rock:0", PuzzleBug{}, false},
+		{"Beetle", PuzzleBug{}, false},
+		{"", PuzzleBug{}, false},
+		{"Small:0+Sword", PuzzleBug{}, false},
+		{"Small:0+Bullet+Bullet+Bullet+Bullet", PuzzleBug{}, false},
+	} {
+		var bug PuzzleBug
+		err := bug.UnmarshalText([]byte(test.text))
+		if (err == nil) != test.valid {
+			t.Errorf("%q: error %v", test.text, err)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		same := bug.Type == test.bug.Type && bug.Color == test.bug.Color && len(bug.Items) == len(test.bug.Items)
+		for k := 0; same && k < len(bug.Items); k++ {
+			same = bug.Items[k] == test.bug.Items[k]
+		}
+		if !same {
+			t.Errorf("%q: %v (expected %v)", test.text, bug, test.bug)
+		}
+		text, err := bug.MarshalText()
+		if err != nil || string(text) != test.text {
+			t.Errorf("%q: marshaled as %q, error %v", test.text, text, err)
+		}
+	}
+}
+
+func TestReadPuzzle(t *testing.T) {
+	vines := func(n int) string {
+		return "[" + strings.Repeat(`["Small:0"],`, n-1) + `["Small:0"]]`
+	}
+	for _, test := range []struct {
+		name  string
+		json  string
+		valid bool
+	}{
+		{"valid", `{"ID": "a", "Moves": 2, "Vines": [["Large:2"], ["Small:0"]], "Inventory": {"Bullet": 1}}`, true},
+		{"largest", `{"ID": "a", "Moves": 2, "Depth": 15, "Vines": ` + vines(MaxPuzzleVines) + `}`, true},
+		{"null", `null`, false},
+		{"malformed", `{"ID": "a",`, false},
+		{"no id", `{"Moves": 2, "Vines": [["Small:0"]]}`, false},
+		{"no moves", `{"ID": "a", "Vines": [["Small:0"]]}`, false},
+		{"negative depth", `{"ID": "a", "Moves": 2, "Depth": -1, "Vines": [["Small:0"]]}`, false},
+		{"deep", `{"ID": "a", "Moves": 2, "Depth": 16, "Vines": [["Small:0"]]}`, false},
+		{"no vines", `{"ID": "a", "Moves": 2, "Vines": []}`, false},
+		{"many vines", `{"ID": "a", "Moves": 2, "Vines": ` + vines(MaxPuzzleVines+1) + `}`, false},
+		{"full vine", `{"ID": "a", "Moves": 2, "Depth": 1, "Vines": [["Small:0", "Small:0"]]}`, false},
+		{"no bugs", `{"ID": "a", "Moves": 2, "Vines": [[], []]}`, false},
+		{"bad bug", `{"ID": "a", "Moves": 2, "Vines": [["Large:1"]]}`, false},
+		{"too many items", `{"ID": "a", "Moves": 2, "Vines": [["Small:0+Bullet+Bullet+Bullet+Bullet"]]}`, false},
+		{"money", `{"ID": "a", "Moves": 2, "Vines": [["Small:0"]], "Inventory": {"MoneyXL": 1}}`, false},
+		{"no items", `{"ID": "a", "Moves": 2, "Vines": [["Small:0"]], "Inventory": {"Bullet": 0}}`, false},
+		{"unknown item", `{"ID": "a", "Moves": 2, "Vines": [["Small:0"]], "Inventory": {"Sword": 1}}`, false},
+	} {
+		p, err := ReadPuzzle(strings.NewReader(test.json))
+		if (err == nil) != test.valid {
+			t.Errorf("%s: error %v", test.name, err)
+			continue
+		}
+		if test.valid && p.ID != "a" {
+			t.Errorf("%s: id %q", test.name, p.ID)
+		}
+	}
+}
+
+func TestShippedPuzzles(t *testing.T) {
+	paths, err := filepath.Glob("../levels/intro-*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no puzzles found")
+	}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := ReadPuzzle(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		g := NewGame(&Config{Puzzle: p})
+		if g.NumCol() != len(p.Vines) || g.MovesLeft() != p.Moves {
+			t.Errorf("%s: %d vines, %d moves", path, g.NumCol(), g.MovesLeft())
+		}
+	}
+}
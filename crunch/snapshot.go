@@ -0,0 +1,113 @@
+package crunch
+
+// Snapshot is a copy of the parts of a Game that the player can see.  A
+// Snapshot shares no memory with its Game so it may be kept and modified
+// freely, which makes it suitable for handing to a computer player.
+type Snapshot struct {
+	Tick     int64
+	NumCol   int
+	ColDepth int
+	Level    int
+	Score    int64
+
+	// Vines holds the bugs on each vine from the canopy down.  Settling is
+	// true for vines on which a chain reaction is resolving, where bugs can
+	// be neither grabbed nor spat.
+	Vines    [][]SnapshotBug
+	Settling []bool
+
+	// Next holds the bugs that will spawn next on each vine.  Next is nil in
+	// puzzles.
+	Next []SnapshotBug
+
+	// Ground holds the items on the ground beneath each vine.
+	Ground [][]ItemType
+
+	// Pos is the column of the player, which may be one to the right of the
+	// last vine.  Held is the bug held by the player or nil.  Inventory is
+	// the player's special items, the first of which is used next.
+	Pos       int
+	Held      *SnapshotBug
+	Inventory []Inv
+	Meter     int
+	Immobile  bool
+	MovesLeft int
+}
+
+// SnapshotBug is a copy of a Bug in a Snapshot.  Color is the color the bug
+// currently has.
+type SnapshotBug struct {
+	Type     BugType
+	Color    Color
+	Eaten    int
+	Exploded bool
+	Items    []ItemType
+}
+
+// Eats returns true if b would eat prey spat onto it.
+func (b SnapshotBug) Eats(prey SnapshotBug) bool {
+	return canEat(b.bug(), prey.bug())
+}
+
+func (b SnapshotBug) bug() *Bug {
+	return &Bug{Type: b.Type, Color: b.Color, Eaten: int8(b.Eaten), Exploded: b.Exploded}
+}
+
+func snapshotBug(b *Bug) SnapshotBug {
+	s := SnapshotBug{
+		Type:     b.Type,
+		Color:    b.ColorEffective(),
+		Eaten:    int(b.Eaten),
+		Exploded: b.Exploded,
+	}
+	for _, item := range b.Items {
+		s.Items = append(s.Items, item.Type)
+	}
+	return s
+}
+
+// Snapshot returns a copy of the parts of g that the player can see.
+func (g *Game) Snapshot() *Snapshot {
+	s := &Snapshot{
+		Tick:      g.ticks,
+		NumCol:    g.config.NumCol,
+		ColDepth:  g.config.ColDepth,
+		Level:     g.Level(),
+		Score:     g.score,
+		Vines:     make([][]SnapshotBug, len(g.vines)),
+		Settling:  make([]bool, len(g.vines)),
+		Ground:    make([][]ItemType, len(g.vines)),
+		Pos:       g.player.pos,
+		Meter:     g.player.meter,
+		Immobile:  !g.now.After(g.player.immobilized),
+		MovesLeft: g.MovesLeft(),
+	}
+	for i, vine := range g.vines {
+		s.Vines[i] = make([]SnapshotBug, len(vine))
+		for j, bug := range vine {
+			s.Vines[i][j] = snapshotBug(bug)
+		}
+		s.Settling[i] = g.columnSettling(i)
+		for _, item := range g.ground.Slot(i) {
+			if item != nil {
+				s.Ground[i] = append(s.Ground[i], item.Type)
+			}
+		}
+	}
+	if g.next != nil {
+		s.Next = make([]SnapshotBug, len(g.next))
+		for i, bug := range g.next {
+			if bug != nil {
+				s.Next[i] = snapshotBug(bug)
+			}
+		}
+	}
+	if g.player.contains != nil {
+		held := snapshotBug(g.player.contains)
+		s.Held = &held
+	}
+	for _, inv := range g.player.itemInv {
+		s.Inventory = append(s.Inventory, *inv)
+	}
+	return s
+}
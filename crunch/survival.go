@@ -0,0 +1,82 @@
+package crunch
+
+import "time"
+
+// SurvivalDifficulty controls how the game difficulty scales with the player's score.
+type SurvivalDifficulty interface {
+	// NumBugInit returns the number of bugs to initializer the game with.
+	NumBugInit() int
+
+	// BugRateInit returns a constant amount of time between bug spawns during
+	// initialization.
+	BugRateInit() float64
+
+	// BugDistribution returns the distribution of bugs and colors
+	BugDistribution(lvl int) BugDistribution
+
+	// NextLevel returns the total score required to achive the next level.
+	NextLevel(lvl int) int64
+
+	// BugRate returns the expected number of seconds between individual bug
+	// spawns for the current level.  An normal distribution will determine the
+	// actual duration between each spawn.
+	BugRate(lvl int) float64
+
+	// ItemRate returns the expected number of seconds between individual item
+	// spawns for the current level and the expected number of seconds for a
+	// spawned item to despawn.  A normal distribution will determine the
+	// actual duration between each spawn.  Another normal distribution
+	// determins the duration each spawn exists.  Multiple items may exist at
+	// the same time.
+	ItemRate(lvl int) (spawn, despawn float64)
+
+	// ItemDistribution returns the distribution of item types seen on the
+	// given level.
+	ItemDistribution(lvl int) ItemDistribution
+
+	// Poison returns how often poison drops on the given level and the
+	// penalty for picking it up.
+	Poison(lvl int) PoisonRules
+
+	// Hazard returns what happens to a player hit by a falling bug or caught
+	// in a blast on the given level.
+	Hazard(lvl int) HazardRules
+}
+
+// HazardRules determine what happens to a player who is hit by a bug falling
+// off a vine or who is caught in the blast of a bomb or lightning bug that
+// detonates on the ground.
+type HazardRules struct {
+	// Stun is how long a hit player cannot move.
+	Stun time.Duration
+
+	// Damage is the number of points a hit player loses.  A score never
+	// drops below zero.
+	Damage int64
+
+	// Lethal ends the game when the player is caught in a blast.
+	Lethal bool
+}
+
+// PoisonRules determine when bugs leave poison on the ground and what
+// happens to a player who picks it up.
+type PoisonRules struct {
+	// RockRate is the probability that a rock falling off a vine leaves
+	// poison.
+	RockRate float64
+
+	// GnatRate is the probability that a crushed gnat leaves poison.
+	GnatRate float64
+
+	// Immobilize is how long a poisoned player cannot move.
+	Immobilize time.Duration
+
+	// Score is the number of points a poisoned player loses.  A score never
+	// drops below zero.
+	Score int64
+
+	// Multiplier scales the bonus of a poisoned player's score multipliers,
+	// the amount by which they exceed one.  The combo multiplier is scaled by
+	// shortening the combo.  It should be between zero and one.
+	Multiplier float64
+}
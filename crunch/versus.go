@@ -0,0 +1,87 @@
+package crunch
+
+import (
+	"log"
+)
+
+// Garbage is junk dropped onto a player's vines by their opponent in a versus
+// game.  Each rock falls onto a random vine and each row pushes a random bug
+// onto every vine, like a spawn.
+type Garbage struct {
+	Rocks int
+	Rows  int
+}
+
+// ChainGarbage returns the garbage sent to the opponent for a chain that
+// dropped money of type typ.  Chains drop larger money as they grow, so larger
+// chains send more garbage.  Chains of fewer than five bugs send none.
+func ChainGarbage(typ ItemType) Garbage {
+	switch typ {
+	case ItemMoneySM:
+		return Garbage{Rocks: 1}
+	case ItemMoneyMD:
+		return Garbage{Rocks: 2}
+	case ItemMoneyLG:
+		return Garbage{Rows: 1}
+	case ItemMoneyXL:
+		return Garbage{Rows: 1, Rocks: 2}
+	case ItemMoneyXXL:
+		return Garbage{Rows: 2}
+	}
+	return Garbage{}
+}
+
+// IsZero returns true if gb holds no garbage.
+func (gb Garbage) IsZero() bool {
+	return gb.Rocks <= 0 && gb.Rows <= 0
+}
+
+// AddGarbage queues gb to drop onto the vines.  Garbage drops on the next
+// tick that bugs are free to spawn, once any chain reaction has settled.
+func (g *Game) AddGarbage(gb Garbage) {
+	g.garbage.Rocks += gb.Rocks
+	g.garbage.Rows += gb.Rows
+}
+
+// Garbage returns the garbage waiting to drop onto the vines.
+func (g *Game) Garbage() Garbage {
+	return g.garbage
+}
+
+// Win ends the game with the player as the winner, as when their opponent in
+// a versus game loses.  Win does nothing if the game is already over.
+func (g *Game) Win() {
+	if g.over {
+		return
+	}
+	log.Printf("game won")
+	g.over = true
+	g.won = true
+}
+
+func (g *Game) dropGarbage() {
+	if g.garbage.IsZero() {
+		return
+	}
+	gb := g.garbage
+	g.garbage = Garbage{}
+
+	var n int
+	for k := 0; k < gb.Rows; k++ {
+		for i := range g.vines {
+			bug := g.randomBug()
+			if bug.Color == ColorMulti {
+				bug.RColor = g.randMultiColor()
+			}
+			g.pushBug(i, bug)
+			n++
+		}
+	}
+	for k := 0; k < gb.Rocks; k++ {
+		g.pushBug(g.rand.Intn(len(g.vines)), g.createBug(BugRock, bugColors[BugRock][0]))
+		n++
+	}
+	log.Printf("rows=%d rocks=%d garbage dropped", gb.Rows, gb.Rocks)
+	g.emit(Event{Type: EventGarbage, Value: int64(n)})
+	g.checkDanger()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+// defaultDifficulty is the name of the difficulty used when none is chosen.
+const defaultDifficulty = "normal"
+
+// builtinProfiles holds the difficulty profiles shipped with the game.
+//
+//go:embed profiles/*.json
+var builtinProfiles embed.FS
+
+// builtinProfileNames are the names of the builtin profiles in the order they
+// are presented to the player.
+var builtinProfileNames = []string{"easy", "normal", "hard"}
+
+// difficultyProfiles are the difficulties the player can choose.
+var difficultyProfiles = readBuiltinProfiles()
+
+func readBuiltinProfiles() []*crunch.Profile {
+	var profiles []*crunch.Profile
+	for _, name := range builtinProfileNames {
+		f, err := builtinProfiles.Open("profiles/" + name + ".json")
+		if err != nil {
+			panic(err)
+		}
+		p, err := crunch.ReadProfile(f)
+		f.Close()
+		if err != nil {
+			panic(err)
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles
+}
+
+// LoadProfiles reads the difficulty profiles in dir, which are files with the
+// extension .json, and makes them available to the player.  A profile with
+// the name of a builtin profile replaces it.
+func LoadProfiles(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		p, err := readProfileFile(path)
+		if err != nil {
+			return fmt.Errorf("invalid profile %v: %v", path, err)
+		}
+		log.Printf("profile=%q path=%q profile loaded", p.Name, path)
+		addProfile(p)
+	}
+	return nil
+}
+
+func readProfileFile(path string) (*crunch.Profile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return crunch.ReadProfile(f)
+}
+
+func addProfile(p *crunch.Profile) {
+	for i := range difficultyProfiles {
+		if difficultyProfiles[i].Name == p.Name {
+			difficultyProfiles[i] = p
+			return
+		}
+	}
+	difficultyProfiles = append(difficultyProfiles, p)
+}
+
+func lookupDifficulty(name string) *crunch.Profile {
+	for _, p := range difficultyProfiles {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// difficultyNames returns the names that can be chosen, separated by commas.
+func difficultyNames() string {
+	names := make([]string, len(difficultyProfiles))
+	for i := range difficultyProfiles {
+		names[i] = difficultyProfiles[i].Name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/bmatsuo/cimoj/bot"
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+// runDuel runs the duel command with args and returns the exit status of the
+// program.  A duel is a networked versus game between two bots, played
+// without a terminal, for testing the network play of two machines.
+func runDuel(config *CrunchConfig, args []string) int {
+	fs := flag.NewFlagSet("duel", flag.ContinueOnError)
+	host := fs.String("host", "", "Atendu kontraŭulon ĉe ĉi tiu adreso")
+	connect := fs.String("connect", "", "Konektiĝu al kontraŭulo ĉe ĉi tiu adreso")
+	script := fs.String("player", bot.Default, "Ludanto ("+bot.Names()+")")
+	err := fs.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if (*host == "") == (*connect == "") {
+		fmt.Fprintln(os.Stderr, "exactly one of -host or -connect is required")
+		return 2
+	}
+	preset := bot.Lookup(*script)
+	if preset == nil {
+		fmt.Fprintf(os.Stderr, "unknown player %q (choose from %s)\n", *script, bot.Names())
+		return 2
+	}
+
+	log.SetOutput(ioutil.Discard)
+
+	var m *netMatch
+	if *host != "" {
+		m, err = hostNetMatch(config, *host)
+	} else {
+		m, err = joinNetMatch(config, *connect)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer m.conn.flush()
+
+	var games [2]*crunch.Game
+	for k := range games {
+		games[k] = crunch.NewGame(config.netSide(k, m).crunchConfig())
+	}
+	ls := newLockstep(m, games, preset.New(m.seed))
+	start := time.Now()
+	for !ls.over() {
+		err = ls.advance(netMaxLag, nil)
+		if err == nil && !ls.waiting.IsZero() {
+			err = ls.wait()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "tick %d: %v\n", ls.step, err)
+			return 1
+		}
+	}
+
+	result := "lost to"
+	switch {
+	case games[0].Over() && games[1].Over():
+		result = "tied with"
+	case games[1].Over():
+		result = "won against"
+	}
+	gameTime := time.Duration(ls.step) * crunch.TickDuration
+	fmt.Printf("%s %s %s after %v\n", config.Player, result, m.opponent, gameTime)
+	fmt.Printf("score %d to %d, level %d to %d\n", games[0].Score(), games[1].Score(), games[0].Level(), games[1].Level())
+	fmt.Printf("%d ticks checked in %v, round trip %v\n", ls.checked, time.Since(start).Round(time.Millisecond), ls.rtt)
+	return 0
+}
@@ -1,17 +1,24 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 )
 
+// maxHighScoreLine is the longest line of a HighScoreFile that will be read.
+const maxHighScoreLine = 1 << 20
+
 // HighScore is a play record for personal records.  The record contains
 // key-value Qual that can contain any qualifying data which can be filtered on
-// later.
+// later.  If Replay is not empty it is the path of a replay file that can be
+// used to verify the score.
 type HighScore struct {
 	GameType string
 	Player   string
@@ -20,6 +27,7 @@ type HighScore struct {
 	Start    time.Time
 	End      time.Time
 	Qual     map[string]string
+	Replay   string `json:",omitempty"`
 }
 
 // ScoreDB stores high scores, possibly for several different players and
@@ -29,8 +37,11 @@ type ScoreDB interface {
 	// record could not be persisted.
 	WriteHighScore(*HighScore) error
 
-	// TopHighScores returns the n highest scores that have been persisted.  If
-	// player is an empty string then scores for all players are returned.  If
+	// TopHighScores returns the n highest scores that have been persisted for
+	// gametype, ordered from highest to lowest.  Equal scores are ordered by
+	// End, so the score that was achieved first ranks higher.  If gametype is
+	// an empty string then scores for all game types are returned.  If player
+	// is an empty string then scores for all players are returned.  If
 	// an even number of qualpairs are given then the returned scores should
 	// all contain the specified Qual data.  Implementations may panic if given
 	// an odd number of qual pairs.
@@ -74,11 +85,15 @@ func (db *HighScoreFile) WriteHighScore(score *HighScore) error {
 	return enc.Encode(score)
 }
 
-// TopHighScores implements HighScoreDB
+// TopHighScores implements HighScoreDB.  Lines of the file which cannot be
+// decoded are logged and skipped.
 func (db *HighScoreFile) TopHighScores(n int, gametype, player string, qualpairs ...string) ([]*HighScore, error) {
 	if len(qualpairs)%2 != 0 {
 		panic("odd length qualifier pairs list")
 	}
+	if n <= 0 {
+		return nil, nil
+	}
 
 	f, err := os.Open(db.path)
 	if os.IsNotExist(err) {
@@ -91,27 +106,45 @@ func (db *HighScoreFile) TopHighScores(n int, gametype, player string, qualpairs
 
 	var scores []*HighScore
 
-	dec := json.NewDecoder(f)
-decloop:
-	for {
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxHighScoreLine)
+	lineno := 0
+scanloop:
+	for scanner.Scan() {
+		lineno++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
 		var score *HighScore
-		err := dec.Decode(&score)
-		if err == io.EOF {
-			break
+		err := json.Unmarshal(line, &score)
+		if err != nil || score == nil {
+			log.Printf("line=%d skipping corrupt high score record: %v", lineno, err)
+			continue
 		}
-		if err != nil {
-			return scores, err
+		if gametype != "" && score.GameType != gametype {
+			continue
 		}
 		if player != "" && score.Player != player {
 			continue
 		}
 		for i := 0; i < len(qualpairs); i += 2 {
 			if score.Qual[qualpairs[i]] != qualpairs[i+1] {
-				continue decloop
+				continue scanloop
 			}
 		}
 		scores = append(scores, score)
 	}
 
-	return scores, nil
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].End.Before(scores[j].End)
+	})
+	if len(scores) > n {
+		scores = scores[:n]
+	}
+
+	return scores, scanner.Err()
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestHighScores(t *testing.T) *HighScoreFile {
+	dir, err := ioutil.TempDir("", "cimoj-scores-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	db, err := NewHighScoreFile(filepath.Join(dir, "cimoj-scores.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(1000, 0).UTC()
+	end := func(min int) time.Time { return start.Add(time.Duration(min) * time.Minute) }
+	for _, score := range []*HighScore{
+		{GameType: "survival", Player: "ana", Score: 300, End: end(9), Qual: map[string]string{"difficulty": "normal"}},
+		{GameType: "survival", Player: "bo", Score: 500, End: end(3), Qual: map[string]string{"difficulty": "hard"}},
+		{GameType: "puzzle", Player: "ana", Score: 900, End: end(1), Qual: map[string]string{"puzzle": "unua"}},
+		{GameType: "survival", Player: "bo", Score: 300, End: end(2), Qual: map[string]string{"difficulty": "normal"}},
+		{GameType: "survival", Player: "cy", Score: 300, End: end(5), Qual: map[string]string{"difficulty": "normal"}},
+		{GameType: "survival", Player: "cy", Score: 100, End: end(1), Qual: map[string]string{"difficulty": "normal"}},
+	} {
+		score.Start = start
+		err := db.WriteHighScore(score)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A record cut short by a crash is followed by more records.
+	f, err := os.OpenFile(db.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteString(`{"GameType": "survival", "Player": "dy", "Sco` + "\n\nnull\n")
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.WriteHighScore(&HighScore{GameType: "survival", Player: "dy", Score: 200, Start: start, End: end(4)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestTopHighScores(t *testing.T) {
+	db := writeTestHighScores(t)
+	for _, test := range []struct {
+		n        int
+		gametype string
+		player   string
+		qual     []string
+		players  []string
+		scores   []int64
+	}{
+		// Equal scores rank by the time they were achieved.
+		{10, "survival", "", nil, []string{"bo", "bo", "cy", "ana", "dy", "cy"}, []int64{500, 300, 300, 300, 200, 100}},
+		{3, "survival", "", nil, []string{"bo", "bo", "cy"}, []int64{500, 300, 300}},
+		{1, "survival", "", nil, []string{"bo"}, []int64{500}},
+		{0, "survival", "", nil, nil, nil},
+		{10, "puzzle", "", nil, []string{"ana"}, []int64{900}},
+		{10, "", "", nil, []string{"ana", "bo", "bo", "cy", "ana", "dy", "cy"}, []int64{900, 500, 300, 300, 300, 200, 100}},
+		{10, "survival", "ana", nil, []string{"ana"}, []int64{300}},
+		{10, "survival", "", []string{"difficulty", "normal"}, []string{"bo", "cy", "ana", "cy"}, []int64{300, 300, 300, 100}},
+		{2, "survival", "cy", []string{"difficulty", "normal"}, []string{"cy", "cy"}, []int64{300, 100}},
+		{10, "survival", "", []string{"difficulty", "easy"}, nil, nil},
+		{10, "versus", "", nil, nil, nil},
+	} {
+		scores, err := db.TopHighScores(test.n, test.gametype, test.player, test.qual...)
+		if err != nil {
+			t.Errorf("%d %q %q %q: %v", test.n, test.gametype, test.player, test.qual, err)
+			continue
+		}
+		ok := len(scores) == len(test.scores)
+		for i := 0; ok && i < len(scores); i++ {
+			ok = scores[i].Player == test.players[i] && scores[i].Score == test.scores[i]
+		}
+		if !ok {
+			var got []string
+			for _, s := range scores {
+				got = append(got, s.Player)
+			}
+			t.Errorf("%d %q %q %q: scores of %q (expected %q)",
+				test.n, test.gametype, test.player, test.qual, got, test.players)
+		}
+	}
+}
+
+func TestTopHighScoresMissingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cimoj-scores-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := NewHighScoreFile(filepath.Join(dir, "cimoj-scores.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scores, err := db.TopHighScores(10, "", "")
+	if err != nil || len(scores) != 0 {
+		t.Fatalf("scores %v, error %v", scores, err)
+	}
+}
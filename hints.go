@@ -1,10 +1,12 @@
 package main
 
+// hints are shown beside the board.  A control name in braces, like
+// {GrabSpit}, is replaced by the key bound to the control.
 var hints = map[string][4]string{
 	"controls": {
-		"Movu premante h kaj l.",
+		"Movu premante {MoveLeft} kaj {MoveRight}.",
 		"",
-		"Prenu kaj kraĉu cimojn per k.",
+		"Prenu kaj kraĉu cimojn per {GrabSpit}.",
 		"",
 	},
 	"feeding": {
@@ -23,7 +25,25 @@ var hints = map[string][4]string{
 		"Vi trovis eron!  Ĝi elbe havas",
 		"specialajn povojn!",
 		"",
-		"Uzu erojn per o.",
+		"Uzu erojn per {ItemUse}.",
+	},
+	"puking": {
+		"Via stomako pleniĝas de cimoj.",
+		"",
+		"Vomu per {Puke} por nutri viajn",
+		"idojn kaj gajni pli da poentoj.",
+	},
+	"poison": {
+		"Fi!  Vi prenis venenon.",
+		"",
+		"Evitu venenon de falantaj ŝtonoj",
+		"kaj dispremitaj muŝetoj.",
+	},
+	"hit": {
+		"Aŭ!  Io falis sur vin.",
+		"",
+		"Ŝtonoj kaj bomboj falas de la",
+		"vitoj.  Foriru de sub ili!",
 	},
 	"dying": {
 		"Via propra morto estas tuja!",
@@ -31,10 +51,58 @@ var hints = map[string][4]string{
 		"Protektu vian kasto!",
 		"",
 	},
+	"solved": {
+		"Gratulon, vi solvis la enigmon!",
+		"",
+		"Komencu denove per 'enter'.",
+		"",
+	},
 	"continuing": {
 		"Bedaŭrinde, vi mortis.",
 		"",
 		"Komencu novan ludo per 'enter'.",
 		"",
 	},
+	"garbage": {
+		"Via kontraŭulo ĵetis rubon sur",
+		"viajn vitojn!",
+		"",
+		"Faru grandajn ĉenojn por respondi.",
+	},
+	"won": {
+		"Gratulon, vi venkis!",
+		"",
+		"Komencu novan ludo per 'enter'.",
+		"",
+	},
+	"lost": {
+		"Viaj vitoj superfluis.",
+		"",
+		"Komencu novan ludo per 'enter'.",
+		"",
+	},
+	"netwon": {
+		"Gratulon, vi venkis!",
+		"",
+		"Eliru per Ctrl+C.",
+		"",
+	},
+	"netlost": {
+		"Viaj vitoj superfluis.",
+		"",
+		"Eliru per Ctrl+C.",
+		"",
+	},
+	"disconnected": {
+		"La konekto al via kontraŭulo",
+		"perdiĝis.",
+		"",
+		"Eliru per Ctrl+C.",
+	},
+	"desync": {
+		"Viaj ludoj malsamiĝis kaj ne",
+		"povas daŭri.",
+		"",
+		"Eliru per Ctrl+C.",
+	},
 }
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JoelOtter/termloop"
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+// Binding is an input that triggers a player control.  A Binding is a special
+// key, like an arrow key, a printable character, or a mouse button.  Any of
+// them may be combined with the Alt modifier.
+//
+// As text a Binding is written "[Alt+]Name".  Name is a single character, a
+// key name such as "Left" or "Space", or a mouse button name such as
+// "MouseLeft" or "MouseWheelUp".
+type Binding struct {
+	Alt   bool
+	Mouse bool
+	Key   termloop.Key
+	Ch    rune
+}
+
+// bindingKeys are the names of special keys and mouse buttons.
+var bindingKeys = []struct {
+	Name  string
+	Key   termloop.Key
+	Mouse bool
+}{
+	{"Left", termloop.KeyArrowLeft, false},
+	{"Right", termloop.KeyArrowRight, false},
+	{"Up", termloop.KeyArrowUp, false},
+	{"Down", termloop.KeyArrowDown, false},
+	{"Space", termloop.KeySpace, false},
+	{"Enter", termloop.KeyEnter, false},
+	{"Tab", termloop.KeyTab, false},
+	{"Backspace", termloop.KeyBackspace2, false},
+	{"Insert", termloop.KeyInsert, false},
+	{"Delete", termloop.KeyDelete, false},
+	{"Home", termloop.KeyHome, false},
+	{"End", termloop.KeyEnd, false},
+	{"PgUp", termloop.KeyPgup, false},
+	{"PgDn", termloop.KeyPgdn, false},
+	{"F1", termloop.KeyF1, false},
+	{"F2", termloop.KeyF2, false},
+	{"F3", termloop.KeyF3, false},
+	{"F4", termloop.KeyF4, false},
+	{"F5", termloop.KeyF5, false},
+	{"F6", termloop.KeyF6, false},
+	{"F7", termloop.KeyF7, false},
+	{"F8", termloop.KeyF8, false},
+	{"F9", termloop.KeyF9, false},
+	{"F10", termloop.KeyF10, false},
+	{"F11", termloop.KeyF11, false},
+	{"F12", termloop.KeyF12, false},
+	{"MouseLeft", termloop.MouseLeft, true},
+	{"MouseMiddle", termloop.MouseMiddle, true},
+	{"MouseRight", termloop.MouseRight, true},
+	{"MouseWheelUp", termloop.MouseWheelUp, true},
+	{"MouseWheelDown", termloop.MouseWheelDown, true},
+}
+
+// ParseBinding parses the text form of a Binding.
+func ParseBinding(text string) (Binding, error) {
+	var b Binding
+	name := text
+	if strings.HasPrefix(name, "Alt+") && len(name) > len("Alt+") {
+		b.Alt = true
+		name = name[len("Alt+"):]
+	}
+	if utf8.RuneCountInString(name) == 1 {
+		b.Ch, _ = utf8.DecodeRuneInString(name)
+		if b.Ch == ' ' {
+			return b, fmt.Errorf("invalid binding %q: use Space", text)
+		}
+		return b, nil
+	}
+	for _, k := range bindingKeys {
+		if k.Name == name {
+			b.Key = k.Key
+			b.Mouse = k.Mouse
+			return b, nil
+		}
+	}
+	return b, fmt.Errorf("unknown key: %q", text)
+}
+
+// String returns the text form of b.
+func (b Binding) String() string {
+	name := string(b.Ch)
+	if b.Ch == 0 {
+		name = fmt.Sprintf("Key(%d)", b.Key)
+		for _, k := range bindingKeys {
+			if k.Key == b.Key && k.Mouse == b.Mouse {
+				name = k.Name
+				break
+			}
+		}
+	}
+	if b.Alt {
+		return "Alt+" + name
+	}
+	return name
+}
+
+// eventBinding returns the Binding matching a termloop event.
+func eventBinding(event termloop.Event) (b Binding, ok bool) {
+	b.Alt = event.Mod&termloop.ModAlt != 0
+	switch event.Type {
+	case termloop.EventMouse:
+		// Releasing a button and dragging the mouse are not bindable.
+		if event.Key == termloop.MouseRelease || event.Mod&termloop.ModMotion != 0 {
+			return b, false
+		}
+		b.Mouse = true
+		b.Key = event.Key
+		return b, true
+	case termloop.EventKey:
+		// BUG:
+		// Checking if the Key value is non-zero is not perfect.  It will not
+		// accept the KeyCtrlTilde key combination.  But right now I think this
+		// may be a deficiency in the termloop package.
+		if event.Key != 0 {
+			b.Key = event.Key
+		} else {
+			b.Ch = event.Ch
+		}
+		return b, true
+	}
+	return b, false
+}
+
+// controls are the player controls that can be bound, in the order they are
+// documented.  Name identifies the control in settings and hints.
+var controls = []struct {
+	Control     crunch.PlayerControl
+	Name        string
+	Description string
+}{
+	{crunch.PlayerMoveLeft, "MoveLeft", "Move left"},
+	{crunch.PlayerMoveRight, "MoveRight", "Move right"},
+	{crunch.PlayerGrabSpit, "GrabSpit", "Grab/Spit bugs"},
+	{crunch.PlayerStomp, "Stomp", "Call out to bugs"},
+	{crunch.PlayerItemForward, "ItemForward", "Cycle items forward"},
+	{crunch.PlayerItemBackward, "ItemBackward", "Cycle items backward"},
+	{crunch.PlayerItemUse, "ItemUse", "Use a picked up item"},
+	{crunch.PlayerPuke, "Puke", "Puke to feed your young"},
+}
+
+func lookupControl(name string) (crunch.PlayerControl, bool) {
+	for _, c := range controls {
+		if c.Name == name {
+			return c.Control, true
+		}
+	}
+	return 0, false
+}
+
+// Bindings maps the names of player controls to the text of the inputs that
+// trigger them.
+type Bindings map[string][]string
+
+// KeyMap is a table of bindings from inputs to player controls.  No input is
+// bound to more than one control.
+type KeyMap struct {
+	Name     string
+	bindings map[crunch.PlayerControl][]Binding
+	controls map[Binding]crunch.PlayerControl
+}
+
+// NewKeyMap returns a KeyMap with the given bindings.  Every control must be
+// bound to at least one input.  An error is returned if bindings name unknown
+// controls or inputs, or if an input is bound to more than one control.
+func NewKeyMap(name string, bindings Bindings) (*KeyMap, error) {
+	m := &KeyMap{
+		Name:     name,
+		bindings: make(map[crunch.PlayerControl][]Binding),
+		controls: make(map[Binding]crunch.PlayerControl),
+	}
+	for ctrlName := range bindings {
+		if _, ok := lookupControl(ctrlName); !ok {
+			return nil, fmt.Errorf("unknown control: %q", ctrlName)
+		}
+	}
+	// Controls are bound in a fixed order so conflicts are always reported
+	// the same way.
+	for _, c := range controls {
+		texts := bindings[c.Name]
+		if len(texts) == 0 {
+			return nil, fmt.Errorf("%s is not bound to any key", c.Name)
+		}
+		for _, text := range texts {
+			b, err := ParseBinding(text)
+			if err != nil {
+				return nil, err
+			}
+			other, ok := m.controls[b]
+			if ok && other != c.Control {
+				return nil, fmt.Errorf("%s is bound to both %s and %s", b, controlName(other), c.Name)
+			}
+			if ok {
+				continue
+			}
+			m.controls[b] = c.Control
+			m.bindings[c.Control] = append(m.bindings[c.Control], b)
+		}
+	}
+	return m, nil
+}
+
+func controlName(ctrl crunch.PlayerControl) string {
+	for _, c := range controls {
+		if c.Control == ctrl {
+			return c.Name
+		}
+	}
+	return fmt.Sprintf("PlayerControl(%d)", ctrl)
+}
+
+// Control returns the player control bound to the input of a termloop event.
+func (m *KeyMap) Control(event termloop.Event) (ctrl crunch.PlayerControl, ok bool) {
+	b, ok := eventBinding(event)
+	if !ok {
+		return 0, false
+	}
+	ctrl, ok = m.controls[b]
+	return ctrl, ok
+}
+
+// Bindings returns the inputs bound to ctrl.
+func (m *KeyMap) Bindings(ctrl crunch.PlayerControl) []Binding {
+	return m.bindings[ctrl]
+}
+
+// Key returns the name of the first input bound to ctrl, for use in hints.
+func (m *KeyMap) Key(ctrl crunch.PlayerControl) string {
+	bindings := m.bindings[ctrl]
+	if len(bindings) == 0 {
+		return "?"
+	}
+	return bindings[0].String()
+}
+
+// expand replaces the names of controls in braces within text, like
+// {GrabSpit}, with the keys bound to them.
+func (m *KeyMap) expand(text string) string {
+	if !strings.Contains(text, "{") {
+		return text
+	}
+	var oldnew []string
+	for _, c := range controls {
+		oldnew = append(oldnew, "{"+c.Name+"}", m.Key(c.Control))
+	}
+	return strings.NewReplacer(oldnew...).Replace(text)
+}
+
+// Doc returns a table documenting the key map, as it appears in
+// docs/en/controls.md.
+func (m *KeyMap) Doc() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "    %-18s%-24s%s\n", "KEYBOARD", "MOUSE", "CONTROL")
+	for _, c := range controls {
+		var keys, mouse []string
+		for _, b := range m.bindings[c.Control] {
+			if b.Mouse {
+				mouse = append(mouse, b.String())
+			} else {
+				keys = append(keys, b.String())
+			}
+		}
+		fmt.Fprintf(&buf, "    %-18s%-24s%s\n", strings.Join(keys, ", "), strings.Join(mouse, ", "), c.Description)
+	}
+	return buf.String()
+}
+
+//go:generate go test -run TestControlsDoc . -args -update
+
+// controlsDoc returns the contents of docs/en/controls.md, which documents
+// every preset key map.  Tests check that the file is up to date and go
+// generate rewrites it.
+func controlsDoc() string {
+	var buf bytes.Buffer
+	buf.WriteString(controlsDocIntro)
+	for i, p := range keyMapPresets {
+		fmt.Fprintf(&buf, "\n%s\n\n", p.About)
+		buf.WriteString(keyMaps[i].Doc())
+	}
+	buf.WriteString(controlsDocBindings)
+	return buf.String()
+}
+
+const controlsDocIntro = `<!-- Generated from keymap.go by go generate.  DO NOT EDIT. -->
+#Controls
+
+The controls are chosen with the Klavoj option or the -keys flag.  The tables
+below are generated by ` + "`cimoj -controls`" + `, which prints the controls currently
+in use.
+`
+
+const controlsDocBindings = `
+Mouse buttons held with Alt are not reported by every terminal.
+
+Individual controls can be rebound in the Bindings setting of
+cimoj-settings.json.  Each control listed replaces the preset's bindings for
+that control.  A key may only be bound to one control.
+
+    "Keys": "vi",
+    "Bindings": {
+        "Puke": ["Up", "Enter", "MouseRight"],
+        "ItemUse": ["Alt+o", "Alt+MouseLeft"]
+    }
+
+The controls are MoveLeft, MoveRight, GrabSpit, Stomp, ItemForward,
+ItemBackward, ItemUse, and Puke.  Keys are single characters, Left, Right, Up,
+Down, Space, Enter, Tab, Backspace, Insert, Delete, Home, End, PgUp, PgDn, F1
+through F12, MouseLeft, MouseMiddle, MouseRight, MouseWheelUp, and
+MouseWheelDown.  Any key may be prefixed with Alt+.
+
+How the game is played is described in [gameplay](gameplay.md).
+`
+
+// sharedBinding returns an input bound to a control in both m and other.
+func (m *KeyMap) sharedBinding(other *KeyMap) (Binding, bool) {
+	// Controls are checked in a fixed order so conflicts are always reported
+	// the same way.
+	for _, c := range controls {
+		for _, b := range m.bindings[c.Control] {
+			if _, ok := other.controls[b]; ok {
+				return b, true
+			}
+		}
+	}
+	return Binding{}, false
+}
+
+// defaultKeyMap is the name of the key map used when none is chosen.
+const defaultKeyMap = "vi"
+
+// keyMapPresets are the key maps the player can choose.  About introduces the
+// preset in docs/en/controls.md.
+var keyMapPresets = []struct {
+	Name     string
+	About    string
+	Bindings Bindings
+}{
+	{"vi", "The vi controls are used by default.", Bindings{
+		"MoveLeft":     {"h", "Left", "MouseWheelUp"},
+		"MoveRight":    {"l", "Right", "MouseWheelDown"},
+		"GrabSpit":     {"k", "Space", "MouseLeft"},
+		"Stomp":        {"j", "Down", "MouseMiddle"},
+		"ItemForward":  {"p", "Alt+MouseWheelUp"},
+		"ItemBackward": {"u", "Alt+MouseWheelDown"},
+		"ItemUse":      {"o", "Alt+MouseLeft"},
+		"Puke":         {"i", "Up", "MouseRight"},
+	}},
+	{"arrows", "The arrows controls.", Bindings{
+		"MoveLeft":     {"Left", "MouseWheelUp"},
+		"MoveRight":    {"Right", "MouseWheelDown"},
+		"GrabSpit":     {"Up", "MouseLeft"},
+		"Stomp":        {"Down", "MouseMiddle"},
+		"ItemForward":  {"c", "Alt+MouseWheelUp"},
+		"ItemBackward": {"z", "Alt+MouseWheelDown"},
+		"ItemUse":      {"x", "Alt+MouseLeft"},
+		"Puke":         {"Space", "MouseRight"},
+	}},
+	{"wasd", "The wasd controls.", Bindings{
+		"MoveLeft":     {"a", "MouseWheelUp"},
+		"MoveRight":    {"d", "MouseWheelDown"},
+		"GrabSpit":     {"w", "MouseLeft"},
+		"Stomp":        {"s", "MouseMiddle"},
+		"ItemForward":  {"e", "Alt+MouseWheelUp"},
+		"ItemBackward": {"q", "Alt+MouseWheelDown"},
+		"ItemUse":      {"f", "Alt+MouseLeft"},
+		"Puke":         {"Space", "MouseRight"},
+	}},
+	{"numpad", "The numpad controls, meant for the second player of a versus game.  Num Lock\nmust be on.", Bindings{
+		"MoveLeft":     {"4"},
+		"MoveRight":    {"6"},
+		"GrabSpit":     {"8"},
+		"Stomp":        {"5", "2"},
+		"ItemForward":  {"9"},
+		"ItemBackward": {"7"},
+		"ItemUse":      {"3", "1"},
+		"Puke":         {"0"},
+	}},
+}
+
+// keyMaps are the preset key maps, built from keyMapPresets.
+var keyMaps = func() []*KeyMap {
+	maps := make([]*KeyMap, len(keyMapPresets))
+	for i, p := range keyMapPresets {
+		m, err := NewKeyMap(p.Name, p.Bindings)
+		if err != nil {
+			panic(err)
+		}
+		maps[i] = m
+	}
+	return maps
+}()
+
+func lookupKeyMap(name string) *KeyMap {
+	for _, m := range keyMaps {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// keyMapNames returns the names that can be chosen, separated by commas.
+func keyMapNames() string {
+	names := make([]string, len(keyMaps))
+	for i := range keyMaps {
+		names[i] = keyMaps[i].Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// buildKeyMap returns the preset key map with the given name after replacing
+// the bindings of any controls in custom.
+func buildKeyMap(name string, custom Bindings) (*KeyMap, error) {
+	var preset Bindings
+	for _, p := range keyMapPresets {
+		if p.Name == name {
+			preset = p.Bindings
+		}
+	}
+	if preset == nil {
+		return nil, fmt.Errorf("unknown Keys %q (choose from %s)", name, keyMapNames())
+	}
+	if len(custom) == 0 {
+		return lookupKeyMap(name), nil
+	}
+	bindings := make(Bindings, len(preset))
+	for ctrl, texts := range preset {
+		bindings[ctrl] = texts
+	}
+	for ctrl, texts := range custom {
+		bindings[ctrl] = texts
+	}
+	return NewKeyMap(name, bindings)
+}
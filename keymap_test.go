@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+var update = flag.Bool("update", false, "rewrite generated files")
+
+func TestControlsDoc(t *testing.T) {
+	const path = "docs/en/controls.md"
+	doc := controlsDoc()
+	if *update {
+		err := ioutil.WriteFile(path, []byte(doc), 0664)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	p, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p) != doc {
+		t.Fatalf("%s is out of date; run go generate", path)
+	}
+}
+
+func bindingNames(bindings []Binding) string {
+	names := make([]string, len(bindings))
+	for i, b := range bindings {
+		names[i] = b.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+func TestBuildKeyMap(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		bindings Bindings
+		ok       bool
+		ctrl     crunch.PlayerControl
+		keys     string
+	}{
+		{"vi", nil, true, crunch.PlayerMoveLeft, "h, Left, MouseWheelUp"},
+		{"arrows", nil, true, crunch.PlayerPuke, "Space, MouseRight"},
+		{"wasd", nil, true, crunch.PlayerItemUse, "f, Alt+MouseLeft"},
+		{"numpad", nil, true, crunch.PlayerStomp, "5, 2"},
+		{"qwerty", nil, false, 0, ""},
+
+		// Settings replace the bindings of a control.
+		{"vi", Bindings{"Puke": {"Enter", "MouseRight"}}, true, crunch.PlayerPuke, "Enter, MouseRight"},
+		{"vi", Bindings{"Stomp": {"Alt+j"}}, true, crunch.PlayerMoveLeft, "h, Left, MouseWheelUp"},
+		{"wasd", Bindings{"GrabSpit": {"Up", "w"}}, true, crunch.PlayerGrabSpit, "Up, w"},
+
+		// A key may only be bound to one control.
+		{"vi", Bindings{"Puke": {"h"}}, false, 0, ""},
+		{"vi", Bindings{"MoveLeft": {"x"}, "MoveRight": {"x"}}, false, 0, ""},
+		{"numpad", Bindings{"ItemUse": {"4"}}, false, 0, ""},
+
+		{"vi", Bindings{"Jump": {"x"}}, false, 0, ""},
+		{"vi", Bindings{"Puke": {"Hyper+x"}}, false, 0, ""},
+		{"vi", Bindings{"Puke": {" "}}, false, 0, ""},
+		{"vi", Bindings{"Puke": {}}, false, 0, ""},
+	} {
+		m, err := buildKeyMap(test.name, test.bindings)
+		if !test.ok {
+			if err == nil {
+				t.Errorf("%s %v: key map built", test.name, test.bindings)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %v: %v", test.name, test.bindings, err)
+			continue
+		}
+		keys := bindingNames(m.Bindings(test.ctrl))
+		if keys != test.keys {
+			t.Errorf("%s %v: %s bound to %q (expected %q)",
+				test.name, test.bindings, controlName(test.ctrl), keys, test.keys)
+		}
+	}
+}
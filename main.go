@@ -2,12 +2,15 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/user"
 	"path/filepath"
 
 	"github.com/JoelOtter/termloop"
+	"github.com/bmatsuo/cimoj/bot"
+	"github.com/bmatsuo/cimoj/crunch"
 )
 
 // GameVersion is used for bookkeeping pursposes
@@ -16,6 +19,28 @@ var GameVersion = "v0.0.1"
 func main() {
 	showMenu := flag.Bool("m", false, "Montru la menuon antaŭ komencu")
 	dataDir := flag.String("d", "tmp", "Dosierujo de ludo datumoj")
+	seed := flag.Int64("seed", 0, "Semo de hazardaj nombroj (0 elektas novan semon por ĉiu ludo)")
+	replayPath := flag.String("replay", "", "Ripetu ludon el registrita dosiero")
+	puzzlePath := flag.String("puzzle", "", "Ludu enigmon el nivela dosiero")
+	verifyPath := flag.String("verify", "", "Kontrolu ke registrita dosiero reproduktas sian poentaron")
+	alias := flag.String("alias", "", "Kaŝnomo de la ludanto")
+	numCol := flag.Int("numcol", 0, "Nombro de kolumnoj")
+	colDepth := flag.Int("depth", 0, "Profundo de kolumnoj")
+	colSpace := flag.Int("colspace", 0, "Spaco inter kolumnoj")
+	colVSpace := flag.Int("colvspace", 0, "Vertikala spaco inter cimoj")
+	smallSize := flag.Int("smallsize", 0, "Larĝo de malgrandaj cimoj")
+	largeSize := flag.Int("largesize", 0, "Larĝo de grandaj cimoj")
+	difficulty := flag.String("difficulty", "", "Malfacileco ("+difficultyNames()+")")
+	theme := flag.String("theme", "", "Koloroj ("+themeNames()+")")
+	keys := flag.String("keys", "", "Klavoj ("+keyMapNames()+")")
+	chainSpeed := flag.String("chainspeed", "", "Rapido de ĉenaj reakcioj ("+chainSpeedNames()+")")
+	locale := flag.String("locale", "", "Lingvo ("+localeNames()+")")
+	versus := flag.String("versus", "", "Ludu kontraŭ dua ludanto kun ĉi tiu kaŝnomo")
+	keys2 := flag.String("keys2", defaultVersusKeys, "Klavoj de la dua ludanto ("+keyMapNames()+")")
+	host := flag.String("host", "", "Atendu kontraŭulon el alia komputilo ĉe ĉi tiu adreso (ekz. :7777)")
+	connect := flag.String("connect", "", "Konektiĝu al kontraŭulo ĉe ĉi tiu adreso")
+	botName := flag.String("bot", "", "Lasu komputilan ludanton ludi por vi, aŭ kontraŭ vi kun -versus ("+bot.Names()+")")
+	printControls := flag.Bool("controls", false, "Presu la klavojn de la ludo kaj eliru")
 	flag.Parse()
 
 	gameDir := GameDir(*dataDir)
@@ -31,34 +56,234 @@ func main() {
 		log.Fatal(err)
 	}
 
-	alias := "player"
+	replayDir := gameDir.Path("cimoj-replays")
+	err = os.MkdirAll(replayDir, 0775)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	packDir := gameDir.Path("cimoj-packs")
+	err = os.MkdirAll(packDir, 0775)
+	if err != nil {
+		log.Fatal(err)
+	}
+	packs, err := FindLevelPacks(packDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	profileDir := gameDir.Path("cimoj-profiles")
+	err = os.MkdirAll(profileDir, 0775)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = LoadProfiles(profileDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	progress, err := OpenProgressFile(gameDir.Path("cimoj-progress.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defaultAlias := "player"
 	usr, err := user.Current()
 	if err != nil {
 		log.Printf("unable to detect username: %v", err)
 	} else if usr.Username == "" {
 		log.Printf("detected user has no username")
+	} else if validateAlias(usr.Username) != nil {
+		log.Printf("username=%q username is not a valid alias", usr.Username)
 	} else {
-		alias = usr.Username
+		defaultAlias = usr.Username
 	}
 
 	config := &CrunchConfig{
-		Player:           alias,
-		Survival:         &simpleSurvivalDifficulty{},
+		Player:           defaultAlias,
+		Survival:         lookupDifficulty(defaultDifficulty),
+		Difficulty:       defaultDifficulty,
+		Theme:            defaultTheme,
+		Keys:             defaultKeyMap,
+		ChainSpeed:       defaultChainSpeed,
+		Locale:           defaultLocale,
 		NumCol:           8,
 		ColSpace:         2,
 		ColDepth:         7,
 		CritterSizeSmall: 1,
 		CritterSizeLarge: 1,
+		Seed:             *seed,
+		ReplayDir:        replayDir,
+		SettingsFile:     gameDir.Path("cimoj-settings.json"),
+	}
+
+	// Settings are read from the settings file and then overridden by any
+	// flags given on the command line.
+	settings := config.settings()
+	err = ReadSettings(config.SettingsFile, settings)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "alias":
+			settings.Player = *alias
+		case "numcol":
+			settings.NumCol = *numCol
+		case "depth":
+			settings.ColDepth = *colDepth
+		case "colspace":
+			settings.ColSpace = *colSpace
+		case "colvspace":
+			settings.ColVSpace = *colVSpace
+		case "smallsize":
+			settings.SmallSize = *smallSize
+		case "largesize":
+			settings.LargeSize = *largeSize
+		case "difficulty":
+			settings.Difficulty = *difficulty
+		case "theme":
+			settings.Theme = *theme
+		case "keys":
+			settings.Keys = *keys
+		case "chainspeed":
+			settings.ChainSpeed = *chainSpeed
+		case "locale":
+			settings.Locale = *locale
+		}
+	})
+	err = config.applySettings(settings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid option: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Subcommands run without a terminal.
+	if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case "sim":
+			os.Exit(runSim(config, flag.Args()[1:]))
+		case "duel":
+			os.Exit(runDuel(config, flag.Args()[1:]))
+		}
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		os.Exit(2)
+	}
+
+	if *botName != "" {
+		if bot.Lookup(*botName) == nil {
+			fmt.Fprintf(os.Stderr, "unknown bot %q (choose from %s)\n", *botName, bot.Names())
+			os.Exit(2)
+		}
+		config.Bot = *botName
+	}
+
+	if *versus != "" {
+		if *puzzlePath != "" || *replayPath != "" || *verifyPath != "" {
+			fmt.Fprintln(os.Stderr, "-versus cannot be used with -puzzle, -replay, or -verify")
+			os.Exit(2)
+		}
+		err := config.setVersus(*versus, *keys2)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid option: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *printControls {
+		fmt.Print(config.keyMap().Doc())
+		return
+	}
+
+	if *host != "" || *connect != "" {
+		if *host != "" && *connect != "" {
+			fmt.Fprintln(os.Stderr, "-host cannot be used with -connect")
+			os.Exit(2)
+		}
+		if *versus != "" || *puzzlePath != "" || *replayPath != "" || *verifyPath != "" {
+			fmt.Fprintln(os.Stderr, "-host and -connect cannot be used with -versus, -puzzle, -replay, or -verify")
+			os.Exit(2)
+		}
+		var m *netMatch
+		if *host != "" {
+			fmt.Fprintf(os.Stderr, "waiting for an opponent at %s\n", *host)
+			m, err = hostNetMatch(config, *host)
+		} else {
+			m, err = joinNetMatch(config, *connect)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		config.Versus = &VersusConfig{Player: m.opponent, net: m}
+		// The game starts at once so the opponent is not kept waiting.
+		*showMenu = false
+	}
+
+	if *puzzlePath != "" {
+		puzzle, err := readPuzzleFile(*puzzlePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		config.setPuzzle(puzzle)
+	}
+
+	if *verifyPath != "" {
+		replay, err := ReadReplay(*verifyPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		err = replay.useDifficulty(config)
+		if err == nil {
+			err = replay.Verify(config.Survival)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: score %d (level %d) verified\n", *verifyPath, replay.Score, replay.Level)
+		return
+	}
+
+	var replay *Replay
+	if *replayPath != "" {
+		replay, err = ReadReplay(*replayPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = replay.useDifficulty(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *replayPath, err)
+			os.Exit(1)
+		}
+		config.NumCol = replay.NumCol
+		config.ColDepth = replay.ColDepth
+		config.setPuzzle(replay.Puzzle)
 	}
 
 	size := config.boardSize()
 	log.Printf("size: %v", size)
 
 	game := termloop.NewGame()
-	app := NewCrunchApp(game, config, scorefile, *showMenu)
+	app := NewCrunchApp(game, config, scorefile, *showMenu, replay)
+	app.SetLevelPacks(packs, progress)
 	app.Start()
 }
 
+func readPuzzleFile(path string) (*crunch.Puzzle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	puzzle, err := crunch.ReadPuzzle(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid puzzle %v: %v", path, err)
+	}
+	return puzzle, nil
+}
+
 // GameDir is a simple construct for accessing paths under the game's data
 // directory.
 type GameDir string
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/JoelOtter/termloop"
@@ -23,6 +24,7 @@ var menuChoices = []string{
 type CrunchMenu struct {
 	config        *CrunchConfig
 	menu          *simpleMenu
+	packs         []*LevelPack
 	textPlayer    *termloop.Text
 	textGameType  *termloop.Text
 	textHighScore *termloop.Text
@@ -70,7 +72,13 @@ func NewCrunchMenu(config *CrunchConfig) *CrunchMenu {
 	stats.AddEntity(m.textPlayer)
 
 	stats.AddEntity(termloop.NewText(0, 2, "Ludo Reĝimo:", fg, bg))
-	m.textGameType = termloop.NewText(14, 2, "Supervivo", fg, bg)
+	gameType := "Supervivo"
+	if config.Puzzle != nil {
+		gameType = "Enigmo: " + config.Puzzle.Name
+	} else if config.Versus != nil {
+		gameType = "Kontraŭ: " + config.Versus.Player
+	}
+	m.textGameType = termloop.NewText(14, 2, gameType, fg, bg)
 	stats.AddEntity(m.textGameType)
 
 	m.menu.SetSelection(0, true)
@@ -78,6 +86,50 @@ func NewCrunchMenu(config *CrunchConfig) *CrunchMenu {
 	return m
 }
 
+// refresh updates the menu after the config or pack progress has changed.
+func (m *CrunchMenu) refresh(packs []*LevelPack, progress *ProgressFile) {
+	m.textPlayer.SetText(m.config.Player)
+	m.setPacks(packs, progress)
+}
+
+// setPacks lists packs in the menu after the fixed menu choices, along with
+// the player's progress through each pack.
+func (m *CrunchMenu) setPacks(packs []*LevelPack, progress *ProgressFile) {
+	m.packs = packs
+
+	choices := append([]string(nil), menuChoices...)
+	for _, pack := range packs {
+		text := "Nivelaro: " + pack.Manifest.Name
+		if pack.Compatible() {
+			solved := progress.Progress(m.config.Player, pack.Manifest.Name).NumSolved(pack)
+			text += fmt.Sprintf(" (%d/%d)", solved, len(pack.Levels))
+		} else {
+			text += fmt.Sprintf(" (bezonas %s)", pack.Manifest.MinGameVersion)
+		}
+		choices = append(choices, text)
+	}
+
+	sel, _ := m.menu.GetSelection()
+	if sel < 0 || sel >= len(choices) {
+		sel = 0
+	}
+	m.level.RemoveEntity(m.menu)
+	m.menu = newSimpleMenu(m.menu.x, m.menu.y, termloop.ColorWhite, termloop.ColorBlack, choices)
+	m.level.AddEntity(m.menu)
+	m.menu.SetSelection(sel, true)
+}
+
+// SelectedPack returns the level pack at the current menu selection or nil if
+// a pack is not selected.
+func (m *CrunchMenu) SelectedPack() *LevelPack {
+	i, _ := m.menu.GetSelection()
+	i -= len(menuChoices)
+	if i < 0 || i >= len(m.packs) {
+		return nil
+	}
+	return m.packs[i]
+}
+
 // GetSelection returns the currently selected menu item.
 func (m *CrunchMenu) GetSelection() (int, string) {
 	return m.menu.GetSelection()
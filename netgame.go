@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/JoelOtter/termloop"
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+// netStallTime is how long a networked game waits for the other machine
+// before telling the player.
+const netStallTime = 100 * time.Millisecond
+
+// createNetGame creates a versus game against a player on another machine.
+// Both boards are stepped by a lockstep instead of by their games.
+func (app *CrunchApp) createNetGame(config *CrunchConfig) *VersusGame {
+	m := config.Versus.net
+	v := &VersusGame{
+		config:  config,
+		scoreDB: app.scoreDB,
+		winner:  -1,
+	}
+	if config.Bot != "" {
+		v.scoreDB = nil
+	}
+	log.Printf("seed=%d player=%q opponent=%q new networked versus game", m.seed, config.Player, m.opponent)
+	var engines [2]*crunch.Game
+	for k := range v.games {
+		v.games[k] = app.createNewGame(config.netSide(k, m))
+		v.games[k].scoreDB = nil
+		v.games[k].netplay = true
+		engines[k] = v.games[k].engine
+	}
+	v.games[0].opponent = v.games[1]
+	v.games[1].opponent = v.games[0]
+	v.ls = newLockstep(m, engines, v.games[0].bot)
+	v.lastStep = config.clock().Now()
+	return v
+}
+
+// stepNet advances both boards of a networked game to the current time, as
+// far as the other machine allows.
+func (v *VersusGame) stepNet() {
+	now := v.config.clock().Now()
+	dt := now.Sub(v.lastStep)
+	v.lastStep = now
+	err := v.ls.advance(dt, func(k int, events []crunch.Event) {
+		v.games[k].handleEvents(events)
+	})
+	if err != nil {
+		v.abort(err)
+		return
+	}
+	v.games[0].textReplay.SetText(v.netStatus())
+}
+
+// netStatus describes the connection for the local player's panel.
+func (v *VersusGame) netStatus() string {
+	if v.ls.stalled() >= netStallTime {
+		return "Reto: atendas..."
+	}
+	return fmt.Sprintf("Reto: %d ms", v.ls.rtt/time.Millisecond)
+}
+
+// tickNet queues the local player's input.
+func (v *VersusGame) tickNet(event termloop.Event) {
+	g := v.games[0]
+	if v.over || g.bot != nil {
+		return
+	}
+	ctl, ok := g.normalizeControlEvent(event)
+	if ok {
+		v.ls.control(ctl)
+	}
+}
+
+// abort abandons a networked game that cannot continue.  Neither player's
+// result is recorded.
+func (v *VersusGame) abort(err error) {
+	log.Printf("networked versus game abandoned: %v", err)
+	v.err = err
+	v.over = true
+	v.finished = true
+	v.ls.close()
+	if _, ok := err.(*desyncError); ok {
+		v.games[0].setHint("desync")
+		v.games[0].textReplay.SetText("Reto: malsamaj")
+	} else {
+		v.games[0].setHint("disconnected")
+		v.games[0].textReplay.SetText("Reto: perdita")
+	}
+}
@@ -0,0 +1,614 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bmatsuo/cimoj/bot"
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+// Networked versus games are played in lockstep.  Both machines simulate both
+// boards from the same seed, one tick at a time, and exchange the input of
+// their player for every tick.  Input is applied netDelay ticks after it is
+// given so that it has time to reach the other machine.  A machine that has
+// not yet received the other player's input for a tick waits for it.
+const (
+	netDelay     = 10
+	netTimeout   = 5 * time.Second
+	netDialTime  = 10 * time.Second
+	netPingRate  = 250 * time.Millisecond
+	netMaxLag    = time.Second
+	netQueueSize = 256
+)
+
+// netMessage is a message between the two machines of a networked versus
+// game.  Messages are sent as lines of JSON.  Type is one of:
+//
+//	hello  the joining player introduces themselves
+//	start  the hosting player chooses the board and the seed
+//	error  the game cannot be played
+//	frame  the input of the sender's player for tick Step
+//	ping   a request for a pong, carrying the time it was sent
+//	pong   the reply to a ping, carrying the time of the ping
+//	bye    the sender has left the game
+//
+// A frame also carries the garbage the sender's board sent on tick Step-Delay
+// and the hash of the sender's board after that tick, which the receiver
+// checks against its own copy of the board.
+type netMessage struct {
+	Type string
+
+	GameVersion string `json:",omitempty"`
+	Player      string `json:",omitempty"`
+	Seed        int64  `json:",omitempty"`
+	NumCol      int    `json:",omitempty"`
+	ColDepth    int    `json:",omitempty"`
+	Difficulty  string `json:",omitempty"`
+	ChainSpeed  string `json:",omitempty"`
+	Delay       int64  `json:",omitempty"`
+	Error       string `json:",omitempty"`
+
+	Step    int64                  `json:",omitempty"`
+	Input   []crunch.PlayerControl `json:",omitempty"`
+	Garbage *crunch.Garbage        `json:",omitempty"`
+	Hash    uint64                 `json:",omitempty"`
+	Time    int64                  `json:",omitempty"`
+}
+
+// errOpponentLeft is returned when the other player has left the game before
+// it ended.
+var errOpponentLeft = errors.New("the opponent left the game")
+
+// desyncError is returned when the two machines disagree about a board.
+type desyncError struct {
+	Step int64
+	What string
+}
+
+func (err *desyncError) Error() string {
+	return fmt.Sprintf("out of sync at tick %d: %s", err.Step, err.What)
+}
+
+// netConn is a connection to the other machine of a networked versus game.
+// Messages are read and written by their own goroutines so the game never
+// blocks on the network.
+type netConn struct {
+	conn net.Conn
+	enc  *json.Encoder
+	dec  *json.Decoder
+	in   chan *netMessage
+	out  chan *netMessage
+	errc chan error
+	done chan struct{} // closed once the connection is closed
+	once sync.Once
+}
+
+func newNetConn(conn net.Conn) *netConn {
+	return &netConn{
+		conn: conn,
+		enc:  json.NewEncoder(conn),
+		dec:  json.NewDecoder(conn),
+		in:   make(chan *netMessage, netQueueSize),
+		out:  make(chan *netMessage, netQueueSize),
+		errc: make(chan error, 1),
+		done: make(chan struct{}),
+	}
+}
+
+// recv reads a message while the game is being set up.
+func (c *netConn) recv() (*netMessage, error) {
+	c.conn.SetReadDeadline(time.Now().Add(netDialTime))
+	defer c.conn.SetReadDeadline(time.Time{})
+	var m *netMessage
+	err := c.dec.Decode(&m)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("empty message")
+	}
+	if m.Type == "error" {
+		return nil, fmt.Errorf("opponent: %s", m.Error)
+	}
+	return m, nil
+}
+
+// start begins reading and writing messages in the background.
+func (c *netConn) start() {
+	go func() {
+		for {
+			var m *netMessage
+			err := c.dec.Decode(&m)
+			if err != nil {
+				c.fail(err)
+				close(c.in)
+				return
+			}
+			if m != nil {
+				c.in <- m
+			}
+		}
+	}()
+	go func() {
+		defer close(c.done)
+		defer c.conn.Close()
+		for m := range c.out {
+			err := c.enc.Encode(m)
+			if err != nil {
+				c.fail(err)
+				return
+			}
+		}
+	}()
+}
+
+func (c *netConn) fail(err error) {
+	select {
+	case c.errc <- err:
+	default:
+	}
+}
+
+// send queues m to be written.  If the other machine has stopped reading the
+// connection fails.
+func (c *netConn) send(m *netMessage) {
+	select {
+	case c.out <- m:
+	default:
+		c.fail(fmt.Errorf("the connection is not keeping up"))
+	}
+}
+
+// close says goodbye and closes the connection once all queued messages are
+// written.
+func (c *netConn) close() {
+	c.once.Do(func() {
+		c.send(&netMessage{Type: "bye"})
+		close(c.out)
+	})
+}
+
+// flush closes the connection and waits for queued messages to be written.
+func (c *netConn) flush() {
+	c.close()
+	select {
+	case <-c.done:
+	case <-time.After(netTimeout):
+	}
+}
+
+// netMatch is a networked versus game agreed on by both players.
+type netMatch struct {
+	conn     *netConn
+	opponent string
+	seed     int64
+	delay    int64
+}
+
+// hostNetMatch waits for a player to join a game at addr.  The game is played
+// on the board described by config.
+func hostNetMatch(config *CrunchConfig, addr string) (*netMatch, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer ln.Close()
+	log.Printf("addr=%q waiting for an opponent", ln.Addr())
+	conn, err := ln.Accept()
+	if err != nil {
+		return nil, err
+	}
+	c := newNetConn(conn)
+	hello, err := c.recv()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if hello.Type != "hello" {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected %q message", hello.Type)
+	}
+	if hello.GameVersion != GameVersion {
+		err := fmt.Errorf("the opponent has version %s and you have %s", hello.GameVersion, GameVersion)
+		c.enc.Encode(&netMessage{Type: "error", Error: err.Error()})
+		conn.Close()
+		return nil, err
+	}
+	err = validateAlias(hello.Player)
+	if err != nil {
+		c.enc.Encode(&netMessage{Type: "error", Error: err.Error()})
+		conn.Close()
+		return nil, err
+	}
+	start := &netMessage{
+		Type:       "start",
+		Player:     config.Player,
+		Seed:       config.seed(),
+		NumCol:     config.NumCol,
+		ColDepth:   config.ColDepth,
+		Difficulty: config.Difficulty,
+		ChainSpeed: config.ChainSpeed,
+		Delay:      netDelay,
+	}
+	err = c.enc.Encode(start)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	log.Printf("addr=%q opponent=%q opponent joined", conn.RemoteAddr(), hello.Player)
+	c.start()
+	return &netMatch{
+		conn:     c,
+		opponent: hello.Player,
+		seed:     start.Seed,
+		delay:    start.Delay,
+	}, nil
+}
+
+// joinNetMatch joins the game hosted at addr.  Config is changed to play on
+// the board chosen by the host.
+func joinNetMatch(config *CrunchConfig, addr string) (*netMatch, error) {
+	conn, err := net.DialTimeout("tcp", addr, netDialTime)
+	if err != nil {
+		return nil, err
+	}
+	c := newNetConn(conn)
+	err = c.enc.Encode(&netMessage{
+		Type:        "hello",
+		GameVersion: GameVersion,
+		Player:      config.Player,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	start, err := c.recv()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if start.Type != "start" {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected %q message", start.Type)
+	}
+	err = config.useNetStart(start)
+	if err != nil {
+		c.enc.Encode(&netMessage{Type: "error", Error: err.Error()})
+		conn.Close()
+		return nil, err
+	}
+	log.Printf("addr=%q opponent=%q joined game", addr, start.Player)
+	c.start()
+	return &netMatch{
+		conn:     c,
+		opponent: start.Player,
+		seed:     start.Seed,
+		delay:    start.Delay,
+	}, nil
+}
+
+// useNetStart changes conf to play on the board chosen by the host of a
+// networked game.
+func (conf *CrunchConfig) useNetStart(start *netMessage) error {
+	profile := lookupDifficulty(start.Difficulty)
+	if profile == nil {
+		return fmt.Errorf("unknown difficulty %q", start.Difficulty)
+	}
+	if lookupChainSpeed(start.ChainSpeed) == nil {
+		return fmt.Errorf("unknown chain speed %q", start.ChainSpeed)
+	}
+	if start.Delay <= 0 {
+		return fmt.Errorf("invalid input delay %d", start.Delay)
+	}
+	err := validateRange("NumCol", start.NumCol, minNumCol, maxNumCol)
+	if err != nil {
+		return err
+	}
+	err = validateRange("ColDepth", start.ColDepth, minColDepth, maxColDepth)
+	if err != nil {
+		return err
+	}
+	err = validateAlias(start.Player)
+	if err != nil {
+		return err
+	}
+	conf.NumCol = start.NumCol
+	conf.ColDepth = start.ColDepth
+	conf.Difficulty = start.Difficulty
+	conf.Survival = profile
+	conf.ChainSpeed = start.ChainSpeed
+	return nil
+}
+
+// netSide returns the config of board k of a networked game, where board 0
+// is played on this machine and board 1 on the other.  The other player's
+// board is drawn to the right.
+func (conf *CrunchConfig) netSide(k int, m *netMatch) *CrunchConfig {
+	side := *conf
+	side.Seed = m.seed
+	side.left = k * conf.gameWidth()
+	if k == 1 {
+		side.Player = m.opponent
+		side.Bot = ""
+	}
+	return &side
+}
+
+// lockstep steps the two boards of a networked game.  Games[0] is played on
+// this machine, by the keyboard or by a bot, and games[1] is played on the
+// other machine.
+type lockstep struct {
+	conn  *netConn
+	games [2]*crunch.Game
+	bot   bot.Player
+	delay int64
+	step  int64 // the next tick to simulate
+	lag   time.Duration
+
+	// input is local input that has not been sent.  Local input and the
+	// frames received from the other machine are kept by tick until used.
+	input   []crunch.PlayerControl
+	local   map[int64][]crunch.PlayerControl
+	remote  map[int64]*netMessage
+	pending int // local controls sent but not yet applied
+
+	// sent is the garbage sent to the other board by tick it lands.  expect
+	// is the garbage the other machine should report for its board, and
+	// hashes are the hashes of the other board by tick.
+	sent   map[int64]crunch.Garbage
+	expect map[int64]crunch.Garbage
+	hashes map[int64]uint64
+
+	waiting  time.Time // when waiting for the other machine began
+	closed   bool      // the other machine has gone
+	lastRecv time.Time
+	lastPing time.Time
+	rtt      time.Duration
+	checked  int64
+}
+
+func newLockstep(m *netMatch, games [2]*crunch.Game, p bot.Player) *lockstep {
+	now := time.Now()
+	return &lockstep{
+		conn:     m.conn,
+		games:    games,
+		bot:      p,
+		delay:    m.delay,
+		local:    make(map[int64][]crunch.PlayerControl),
+		remote:   make(map[int64]*netMessage),
+		sent:     make(map[int64]crunch.Garbage),
+		expect:   make(map[int64]crunch.Garbage),
+		hashes:   make(map[int64]uint64),
+		lastRecv: now,
+		lastPing: now,
+	}
+}
+
+// control queues input from the local player.
+func (ls *lockstep) control(ctl crunch.PlayerControl) {
+	ls.input = append(ls.input, ctl)
+}
+
+// over returns true once either board has ended.  The boards end on the same
+// tick on both machines.
+func (ls *lockstep) over() bool {
+	return ls.games[0].Over() || ls.games[1].Over()
+}
+
+// stalled returns how long the game has been waiting for the other machine.
+func (ls *lockstep) stalled() time.Duration {
+	if ls.waiting.IsZero() {
+		return 0
+	}
+	return time.Since(ls.waiting)
+}
+
+// close ends the game on the connection.
+func (ls *lockstep) close() {
+	ls.conn.close()
+}
+
+// advance simulates the ticks within dt for which input from the other machine
+// has arrived.  Handle, if not nil, is given the events of each board after
+// every tick.
+func (ls *lockstep) advance(dt time.Duration, handle func(k int, events []crunch.Event)) error {
+	err := ls.receive()
+	if err != nil {
+		return err
+	}
+	ls.lag += dt
+	if ls.lag > netMaxLag {
+		ls.lag = netMaxLag
+	}
+	for ls.lag >= crunch.TickDuration && !ls.over() {
+		ok, err := ls.tick(handle)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if ls.waiting.IsZero() {
+				ls.waiting = time.Now()
+			}
+			return nil
+		}
+		ls.waiting = time.Time{}
+		ls.lag -= crunch.TickDuration
+	}
+	return nil
+}
+
+// wait blocks until a message arrives from the other machine.
+func (ls *lockstep) wait() error {
+	timer := time.NewTimer(netPingRate)
+	defer timer.Stop()
+	select {
+	case m, ok := <-ls.conn.in:
+		if ok {
+			ls.handle(m)
+		}
+	case <-timer.C:
+	}
+	return ls.receive()
+}
+
+// receive handles the messages that have arrived from the other machine.
+func (ls *lockstep) receive() error {
+	for {
+		select {
+		case m, ok := <-ls.conn.in:
+			if !ok {
+				ls.closed = true
+				return ls.ping()
+			}
+			ls.handle(m)
+		default:
+			return ls.ping()
+		}
+	}
+}
+
+func (ls *lockstep) handle(m *netMessage) {
+	ls.lastRecv = time.Now()
+	switch m.Type {
+	case "frame":
+		ls.remote[m.Step] = m
+	case "ping":
+		ls.conn.send(&netMessage{Type: "pong", Time: m.Time})
+	case "pong":
+		ls.rtt = time.Since(time.Unix(0, m.Time))
+	case "bye":
+		ls.closed = true
+	default:
+		log.Printf("type=%q unexpected message", m.Type)
+	}
+}
+
+// ping keeps the connection alive and measures the round trip time.  An
+// error is returned if the other machine has gone quiet.
+func (ls *lockstep) ping() error {
+	select {
+	case err := <-ls.conn.errc:
+		if !ls.closed {
+			log.Printf("connection failed: %v", err)
+		}
+		ls.closed = true
+	default:
+	}
+	now := time.Now()
+	if !ls.closed && now.Sub(ls.lastRecv) > netTimeout {
+		return fmt.Errorf("no word from the opponent for %v", netTimeout)
+	}
+	if now.Sub(ls.lastPing) >= netPingRate {
+		ls.lastPing = now
+		ls.conn.send(&netMessage{Type: "ping", Time: now.UnixNano()})
+	}
+	return nil
+}
+
+// tick simulates one tick of both boards.  Tick returns false if the input of
+// the other machine has not arrived.
+func (ls *lockstep) tick(handle func(k int, events []crunch.Event)) (bool, error) {
+	k := ls.step
+	var remote []crunch.PlayerControl
+	if k >= ls.delay {
+		m, ok := ls.remote[k]
+		if !ok {
+			if ls.closed {
+				return false, errOpponentLeft
+			}
+			return false, nil
+		}
+		delete(ls.remote, k)
+		err := ls.check(k, m)
+		if err != nil {
+			return false, err
+		}
+		remote = m.Input
+		if m.Garbage != nil {
+			ls.games[0].AddGarbage(*m.Garbage)
+		}
+	}
+	if gb, ok := ls.sent[k]; ok {
+		delete(ls.sent, k)
+		ls.games[1].AddGarbage(gb)
+	}
+	local := ls.local[k]
+	delete(ls.local, k)
+	ls.pending -= len(local)
+
+	var made [2]crunch.Garbage
+	for i, input := range [2][]crunch.PlayerControl{local, remote} {
+		events := ls.games[i].Step(input, crunch.TickDuration)
+		made[i] = eventGarbage(events)
+		if handle != nil {
+			handle(i, events)
+		}
+	}
+	ls.step++
+
+	// Input given now is applied after the delay, on both machines.  A bot
+	// plays as if there were no delay, waiting to see the result of its
+	// controls before choosing more.
+	if ls.bot != nil && ls.pending == 0 {
+		ls.input = append(ls.input, ls.bot.Play(ls.games[0].Snapshot())...)
+	}
+	next := k + ls.delay
+	frame := &netMessage{
+		Type:  "frame",
+		Step:  next,
+		Input: ls.input,
+		Hash:  ls.games[0].Hash(),
+	}
+	if !made[0].IsZero() {
+		frame.Garbage = &made[0]
+		ls.sent[next] = made[0]
+	}
+	ls.local[next] = ls.input
+	ls.pending += len(ls.input)
+	ls.input = nil
+	ls.expect[next] = made[1]
+	ls.hashes[k] = ls.games[1].Hash()
+	ls.conn.send(frame)
+	return true, nil
+}
+
+// check compares the frame for tick k with this machine's copy of the other
+// board.
+func (ls *lockstep) check(k int64, m *netMessage) error {
+	j := k - ls.delay
+	hash, ok := ls.hashes[j]
+	delete(ls.hashes, j)
+	if ok && hash != m.Hash {
+		return &desyncError{Step: j, What: fmt.Sprintf("board hash %x, opponent has %x", hash, m.Hash)}
+	}
+	want := ls.expect[k]
+	delete(ls.expect, k)
+	var got crunch.Garbage
+	if m.Garbage != nil {
+		got = *m.Garbage
+	}
+	if got != want {
+		return &desyncError{Step: j, What: fmt.Sprintf("garbage %+v, opponent sent %+v", want, got)}
+	}
+	ls.checked++
+	return nil
+}
+
+// eventGarbage returns the garbage sent by the chains among events.
+func eventGarbage(events []crunch.Event) crunch.Garbage {
+	var gb crunch.Garbage
+	for _, e := range events {
+		if e.Type == crunch.EventChain {
+			g := crunch.ChainGarbage(e.Item)
+			gb.Rocks += g.Rocks
+			gb.Rows += g.Rows
+		}
+	}
+	return gb
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/bmatsuo/cimoj/bot"
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+// testNet is a networked versus game between two machines connected by a
+// pipe.  The greedy bot plays on the first machine and the random bot on the
+// second.
+type testNet struct {
+	ls [2]*lockstep
+
+	// hashes are the hashes of both boards on each machine after every tick
+	// and garbage counts the garbage that dropped onto each board.
+	hashes  [2]map[int64][2]uint64
+	garbage [2][2]int
+}
+
+func newTestNet(t *testing.T, seed int64) *testNet {
+	conns := [2]net.Conn{}
+	conns[0], conns[1] = net.Pipe()
+	n := &testNet{}
+	d := lookupDifficulty(defaultDifficulty)
+	players := []string{"greedy", "random"}
+	for s, c := range conns {
+		conn := newNetConn(c)
+		conn.start()
+		m := &netMatch{conn: conn, opponent: "tester", seed: seed, delay: netDelay}
+		var games [2]*crunch.Game
+		for k := range games {
+			games[k] = crunch.NewGame(&crunch.Config{NumCol: 6, ColDepth: 7, Survival: d, Seed: seed})
+		}
+		n.ls[s] = newLockstep(m, games, bot.Lookup(players[s]).New(seed))
+		n.hashes[s] = make(map[int64][2]uint64)
+	}
+	t.Cleanup(func() {
+		for s := range n.ls {
+			n.ls[s].close()
+			conns[s].Close()
+		}
+	})
+	return n
+}
+
+// advance advances machine s by a tick, first waiting for the other machine
+// if machine s is stalled.
+func (n *testNet) advance(s int) error {
+	ls := n.ls[s]
+	if ls.stalled() > 0 {
+		err := ls.wait()
+		if err != nil {
+			return err
+		}
+	}
+	return ls.advance(crunch.TickDuration, func(k int, events []crunch.Event) {
+		n.garbage[s][k] += countGarbage(events)
+		if k == 1 {
+			n.hashes[s][ls.step+1] = [2]uint64{ls.games[0].Hash(), ls.games[1].Hash()}
+		}
+	})
+}
+
+// run advances both machines until they have simulated the given number of
+// ticks or the game is over.  The machine that failed is returned with any
+// error.
+func (n *testNet) run(ticks int64) (int, error) {
+	for {
+		done := true
+		for s, ls := range n.ls {
+			if ls.step >= ticks || ls.over() {
+				continue
+			}
+			done = false
+			err := n.advance(s)
+			if err != nil {
+				return s, err
+			}
+		}
+		if done {
+			return -1, nil
+		}
+	}
+}
+
+func countGarbage(events []crunch.Event) int {
+	var n int
+	for _, e := range events {
+		if e.Type == crunch.EventGarbage {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLockstepSync(t *testing.T) {
+	n := newTestNet(t, 4)
+	s, err := n.run(20000)
+	if err != nil {
+		t.Fatalf("machine %d: %v", s, err)
+	}
+	a, b := n.ls[0], n.ls[1]
+	if a.step != b.step || !a.over() || !b.over() {
+		t.Fatalf("machines stopped at ticks %d and %d", a.step, b.step)
+	}
+	if a.checked < a.step-a.delay || b.checked < b.step-b.delay {
+		t.Fatalf("only %d and %d of %d ticks checked", a.checked, b.checked, a.step)
+	}
+
+	// Each machine's board matches the other's copy of it after every tick.
+	var compared int
+	for k, ha := range n.hashes[0] {
+		hb, ok := n.hashes[1][k]
+		if !ok {
+			continue
+		}
+		if ha[0] != hb[1] || ha[1] != hb[0] {
+			t.Fatalf("tick %d: boards differ: %x %x, %x %x", k, ha[0], hb[1], ha[1], hb[0])
+		}
+		compared++
+	}
+	if compared < int(a.step)/2 {
+		t.Fatalf("only %d of %d ticks compared", compared, a.step)
+	}
+	for k := 0; k < 2; k++ {
+		if a.games[k].Score() != b.games[1-k].Score() || a.games[k].Won() != b.games[1-k].Won() {
+			t.Fatalf("board %d: the machines disagree on the result", k)
+		}
+	}
+
+	if a.games[0].Hash() == a.games[1].Hash() {
+		t.Fatal("the bots played the same game")
+	}
+
+	// Chains on each board drop garbage onto the other board.
+	if n.garbage[0][0] == 0 || n.garbage[0][1] == 0 {
+		t.Fatalf("garbage dropped %v", n.garbage[0])
+	}
+	if n.garbage[0] != [2]int{n.garbage[1][1], n.garbage[1][0]} {
+		t.Fatalf("garbage dropped %v and %v", n.garbage[0], n.garbage[1])
+	}
+}
+
+func TestLockstepDesync(t *testing.T) {
+	n := newTestNet(t, 4)
+	s, err := n.run(200)
+	if err != nil {
+		t.Fatalf("machine %d: %v", s, err)
+	}
+
+	// Garbage that only one machine knows about changes its board.
+	n.ls[0].games[0].AddGarbage(crunch.Garbage{Rocks: 1})
+	s, err = n.run(400)
+	var desync *desyncError
+	if !errors.As(err, &desync) {
+		t.Fatalf("machine %d: %v", s, err)
+	}
+	if s != 1 || desync.Step < 200 {
+		t.Fatalf("machine %d: %v", s, err)
+	}
+}
+
+func TestLockstepDisconnect(t *testing.T) {
+	n := newTestNet(t, 4)
+	s, err := n.run(200)
+	if err != nil {
+		t.Fatalf("machine %d: %v", s, err)
+	}
+	n.ls[1].close()
+	for k := 0; k < 1000; k++ {
+		err = n.advance(0)
+		if err != nil {
+			break
+		}
+	}
+	if err != errOpponentLeft {
+		t.Fatalf("error %v (expected %v)", err, errOpponentLeft)
+	}
+	if n.ls[0].step > n.ls[1].step+n.ls[0].delay {
+		t.Fatalf("tick %d simulated without input", n.ls[0].step)
+	}
+}
+
+func TestUseNetStart(t *testing.T) {
+	valid := func() *netMessage {
+		return &netMessage{
+			Type:       "start",
+			Player:     "host",
+			NumCol:     10,
+			ColDepth:   9,
+			Difficulty: defaultDifficulty,
+			ChainSpeed: defaultChainSpeed,
+			Delay:      netDelay,
+		}
+	}
+	for _, test := range []struct {
+		name   string
+		change func(m *netMessage)
+		ok     bool
+	}{
+		{"valid", func(m *netMessage) {}, true},
+		{"difficulty", func(m *netMessage) { m.Difficulty = "impossible" }, false},
+		{"chain speed", func(m *netMessage) { m.ChainSpeed = "warp" }, false},
+		{"delay", func(m *netMessage) { m.Delay = 0 }, false},
+		{"few columns", func(m *netMessage) { m.NumCol = minNumCol - 1 }, false},
+		{"many columns", func(m *netMessage) { m.NumCol = maxNumCol + 1 }, false},
+		{"shallow", func(m *netMessage) { m.ColDepth = minColDepth - 1 }, false},
+		{"deep", func(m *netMessage) { m.ColDepth = maxColDepth + 1 }, false},
+		{"no alias", func(m *netMessage) { m.Player = "" }, false},
+		{"bad alias", func(m *netMessage) { m.Player = "host\n" }, false},
+	} {
+		start := valid()
+		test.change(start)
+		config := testConfig()
+		err := config.useNetStart(start)
+		if (err == nil) != test.ok {
+			t.Errorf("%s: error %v", test.name, err)
+			continue
+		}
+		if !test.ok {
+			if config.NumCol != testConfig().NumCol || config.ColDepth != testConfig().ColDepth {
+				t.Errorf("%s: the board changed", test.name)
+			}
+			continue
+		}
+		if config.NumCol != start.NumCol || config.ColDepth != start.ColDepth || config.Difficulty != start.Difficulty {
+			t.Errorf("%s: board %dx%d %s", test.name, config.NumCol, config.ColDepth, config.Difficulty)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/JoelOtter/termloop"
+)
+
+// optionsRow is an option on the OptionsView.  Change moves the option's value
+// delta steps forward or backward.
+type optionsRow struct {
+	Label  string
+	Value  func(conf *CrunchConfig) string
+	Change func(conf *CrunchConfig, delta int) error
+	Board  bool // the option changes the shape of the board
+}
+
+var optionsRows = []*optionsRow{
+	{
+		Label: "Kaŝnomo",
+		Value: func(conf *CrunchConfig) string { return conf.Player },
+	},
+	{
+		Label: "Kolumnoj",
+		Value: func(conf *CrunchConfig) string { return fmt.Sprint(conf.NumCol) },
+		Change: func(conf *CrunchConfig, delta int) error {
+			conf.NumCol = clampInt(conf.NumCol+delta, minNumCol, maxNumCol)
+			return nil
+		},
+		Board: true,
+	},
+	{
+		Label: "Profundo",
+		Value: func(conf *CrunchConfig) string { return fmt.Sprint(conf.ColDepth) },
+		Change: func(conf *CrunchConfig, delta int) error {
+			conf.ColDepth = clampInt(conf.ColDepth+delta, minColDepth, maxColDepth)
+			return nil
+		},
+		Board: true,
+	},
+	{
+		Label: "Kolumna Spaco",
+		Value: func(conf *CrunchConfig) string { return fmt.Sprint(conf.ColSpace) },
+		Change: func(conf *CrunchConfig, delta int) error {
+			conf.ColSpace = clampInt(conf.ColSpace+delta, minColSpace, maxColSpace)
+			return nil
+		},
+	},
+	{
+		Label: "Vertikala Spaco",
+		Value: func(conf *CrunchConfig) string { return fmt.Sprint(conf.ColVSpace) },
+		Change: func(conf *CrunchConfig, delta int) error {
+			conf.ColVSpace = clampInt(conf.ColVSpace+delta, minColVSpace, maxColVSpace)
+			return nil
+		},
+	},
+	{
+		Label: "Malgrandaj Cimoj",
+		Value: func(conf *CrunchConfig) string { return fmt.Sprint(conf.CritterSizeSmall) },
+		Change: func(conf *CrunchConfig, delta int) error {
+			conf.CritterSizeSmall = clampInt(conf.CritterSizeSmall+delta, minCritterSize, conf.CritterSizeLarge)
+			return nil
+		},
+	},
+	{
+		Label: "Grandaj Cimoj",
+		Value: func(conf *CrunchConfig) string { return fmt.Sprint(conf.CritterSizeLarge) },
+		Change: func(conf *CrunchConfig, delta int) error {
+			conf.CritterSizeLarge = clampInt(conf.CritterSizeLarge+delta, minCritterSize, maxCritterSize)
+			// Small bugs are never drawn larger than large bugs.
+			if conf.CritterSizeSmall > conf.CritterSizeLarge {
+				conf.CritterSizeSmall = conf.CritterSizeLarge
+			}
+			return nil
+		},
+	},
+	{
+		Label: "Malfacileco",
+		Value: func(conf *CrunchConfig) string { return conf.Difficulty },
+		Change: func(conf *CrunchConfig, delta int) error {
+			i := 0
+			for i < len(difficultyProfiles) && difficultyProfiles[i].Name != conf.Difficulty {
+				i++
+			}
+			d := difficultyProfiles[cycleIndex(i, delta, len(difficultyProfiles))]
+			conf.Difficulty = d.Name
+			conf.Survival = d
+			return nil
+		},
+	},
+	{
+		Label: "Koloroj",
+		Value: func(conf *CrunchConfig) string { return conf.Theme },
+		Change: func(conf *CrunchConfig, delta int) error {
+			i := 0
+			for i < len(colorThemes) && colorThemes[i].Name != conf.Theme {
+				i++
+			}
+			conf.Theme = colorThemes[cycleIndex(i, delta, len(colorThemes))].Name
+			return nil
+		},
+	},
+	{
+		Label: "Ĉenrapido",
+		Value: func(conf *CrunchConfig) string { return conf.ChainSpeed },
+		Change: func(conf *CrunchConfig, delta int) error {
+			i := 0
+			for i < len(chainSpeeds) && chainSpeeds[i].Name != conf.ChainSpeed {
+				i++
+			}
+			conf.ChainSpeed = chainSpeeds[cycleIndex(i, delta, len(chainSpeeds))].Name
+			return nil
+		},
+	},
+	{
+		Label: "Klavoj",
+		Value: func(conf *CrunchConfig) string { return conf.Keys },
+		Change: func(conf *CrunchConfig, delta int) error {
+			i := 0
+			for i < len(keyMaps) && keyMaps[i].Name != conf.Keys {
+				i++
+			}
+			return conf.setKeys(keyMaps[cycleIndex(i, delta, len(keyMaps))].Name)
+		},
+	},
+	{
+		Label: "Lingvo",
+		Value: func(conf *CrunchConfig) string { return conf.Locale },
+		Change: func(conf *CrunchConfig, delta int) error {
+			i := 0
+			for i < len(locales) && locales[i] != conf.Locale {
+				i++
+			}
+			conf.Locale = locales[cycleIndex(i, delta, len(locales))]
+			return nil
+		},
+	},
+}
+
+// OptionsView is an interactive screen for editing a CrunchConfig.  Changes
+// are made to the config immediately and saved to the config's SettingsFile
+// when the view is closed.
+type OptionsView struct {
+	config     *CrunchConfig
+	sel        int
+	editing    bool
+	alias      []rune
+	done       bool
+	width      int
+	height     int
+	textRows   []*termloop.Text
+	textSize   *termloop.Text
+	textStatus *termloop.Text
+	level      *termloop.BaseLevel
+}
+
+// NewOptionsView creates an options screen editing config.
+func NewOptionsView(config *CrunchConfig) *OptionsView {
+	v := &OptionsView{
+		config: config,
+	}
+	fg := termloop.ColorWhite
+	bg := termloop.ColorBlack
+
+	v.level = termloop.NewBaseLevel(termloop.Cell{
+		Fg: fg,
+		Bg: bg,
+		Ch: ' ',
+	})
+
+	v.level.AddEntity(termloop.NewText(2, 1, "Opcioj", fg|termloop.AttrBold, bg))
+	v.textRows = make([]*termloop.Text, len(optionsRows))
+	for i := range optionsRows {
+		v.textRows[i] = termloop.NewText(2, 3+i, "", fg, bg)
+		v.level.AddEntity(v.textRows[i])
+	}
+	v.textSize = termloop.NewText(2, 4+len(optionsRows), "", termloop.ColorGreen, bg)
+	v.level.AddEntity(v.textSize)
+	v.textStatus = termloop.NewText(2, 5+len(optionsRows), "", termloop.ColorRed, bg)
+	v.level.AddEntity(v.textStatus)
+	help := "j/k: movu  h/l: ŝanĝu  Enter: redaktu kaŝnomon  Esc: konservu kaj reen"
+	v.level.AddEntity(termloop.NewText(2, 7+len(optionsRows), help, termloop.ColorCyan, bg))
+
+	v.update()
+
+	return v
+}
+
+// Done returns true once the options have been saved and the view can be
+// closed.
+func (v *OptionsView) Done() bool {
+	return v.done
+}
+
+func (v *OptionsView) update() {
+	for i, row := range optionsRows {
+		arrow := " "
+		fg := termloop.ColorWhite
+		if i == v.sel {
+			arrow = "»"
+			fg |= termloop.AttrUnderline
+		}
+		value := row.Value(v.config)
+		if i == 0 && v.editing {
+			value = string(v.alias) + "_"
+		}
+		v.textRows[i].SetText(fmt.Sprintf("%s %-16s %s", arrow, row.Label+":", value))
+		v.textRows[i].SetColor(fg, termloop.ColorBlack)
+	}
+
+	size := v.config.screenSize()
+	if v.width == 0 {
+		v.textSize.SetText(fmt.Sprintf("Bezonata grandeco: %dx%d", size.X, size.Y))
+	} else {
+		v.textSize.SetText(fmt.Sprintf("Bezonata grandeco: %dx%d (terminalo %dx%d)", size.X, size.Y, v.width, v.height))
+	}
+}
+
+// fits returns true if a game with conf can be displayed in the terminal.  If
+// the size of the terminal is not yet known fits returns true.
+func (v *OptionsView) fits(conf *CrunchConfig) bool {
+	if v.width == 0 {
+		return true
+	}
+	size := conf.screenSize()
+	return size.X <= v.width && size.Y <= v.height
+}
+
+func (v *OptionsView) change(delta int) {
+	row := optionsRows[v.sel]
+	if row.Change == nil {
+		return
+	}
+	if row.Board && v.config.Puzzle != nil {
+		v.textStatus.SetText("La enigmo difinas la tabulon.")
+		return
+	}
+
+	// Changes which make the board too large for the terminal are rejected.
+	// Changes which shrink a board that is already too large are allowed.
+	prev := *v.config
+	err := row.Change(v.config, delta)
+	if err != nil {
+		*v.config = prev
+		v.textStatus.SetText(fmt.Sprintf("Eraro: %v", err))
+		return
+	}
+	if !v.fits(v.config) {
+		oldSize, newSize := prev.screenSize(), v.config.screenSize()
+		if newSize.X > oldSize.X || newSize.Y > oldSize.Y {
+			*v.config = prev
+			v.textStatus.SetText("Ne konvenas al la terminalo.")
+			return
+		}
+	}
+	v.textStatus.SetText("")
+}
+
+// save writes the config's settings and marks the view done.
+func (v *OptionsView) save() {
+	if v.config.SettingsFile != "" {
+		err := WriteSettings(v.config.SettingsFile, v.config.settings())
+		if err != nil {
+			log.Printf("unable to save settings: %v", err)
+			v.textStatus.SetText(fmt.Sprintf("Eraro: %v", err))
+			return
+		}
+	}
+	v.done = true
+}
+
+// Draw implements termloop.Drawable
+func (v *OptionsView) Draw(screen *termloop.Screen) {
+	width, height := screen.Size()
+	if width != v.width || height != v.height {
+		v.width, v.height = width, height
+		v.update()
+	}
+	v.level.Draw(screen)
+}
+
+// Tick implements termloop.Drawable
+func (v *OptionsView) Tick(event termloop.Event) {
+	if event.Type != termloop.EventKey {
+		return
+	}
+	if v.editing {
+		v.tickEditing(event)
+		v.update()
+		return
+	}
+
+	switch event.Key {
+	case termloop.KeyEsc:
+		v.save()
+		return
+	case termloop.KeyEnter:
+		if v.sel == 0 {
+			v.editing = true
+			v.alias = []rune(v.config.Player)
+		}
+	}
+	switch event.Ch {
+	case 'k':
+		v.sel = clampInt(v.sel-1, 0, len(optionsRows)-1)
+	case 'j':
+		v.sel = clampInt(v.sel+1, 0, len(optionsRows)-1)
+	case 'h':
+		v.change(-1)
+	case 'l':
+		v.change(1)
+	}
+	v.update()
+}
+
+func (v *OptionsView) tickEditing(event termloop.Event) {
+	switch event.Key {
+	case termloop.KeyEsc:
+		v.editing = false
+		return
+	case termloop.KeyEnter:
+		if len(v.alias) > 0 {
+			v.config.Player = string(v.alias)
+		}
+		v.editing = false
+		return
+	case termloop.KeyBackspace, termloop.KeyBackspace2:
+		if len(v.alias) > 0 {
+			v.alias = v.alias[:len(v.alias)-1]
+		}
+		return
+	}
+	if event.Key == 0 && len(v.alias) < maxAliasLength && validAliasRune(event.Ch) {
+		v.alias = append(v.alias, event.Ch)
+	}
+}
+
+func clampInt(x, min, max int) int {
+	if x < min {
+		return min
+	}
+	if x > max {
+		return max
+	}
+	return x
+}
+
+// cycleIndex returns the index delta steps away from i in a list of length n,
+// wrapping around at either end.
+func cycleIndex(i, delta, n int) int {
+	return ((i+delta)%n + n) % n
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+// PackManifestName is the name of the manifest file at the root of a level
+// pack.
+const PackManifestName = "pack.json"
+
+// PackManifest describes a LevelPack.  Levels are the paths of the pack's
+// puzzle files, relative to the root of the pack and separated by slashes.
+// Levels are played in the order they are listed.
+//
+//	{
+//		"Name": "Enkonduko",
+//		"Author": "bmatsuo",
+//		"Version": "v1.0.0",
+//		"MinGameVersion": "v0.0.1",
+//		"Levels": ["intro-1.json", "intro-2.json"]
+//	}
+type PackManifest struct {
+	Name           string
+	Author         string
+	Version        string
+	MinGameVersion string
+	Levels         []string
+}
+
+// LevelPack is a collection of puzzles that can be shared between players.  A
+// level pack is either a directory or a zip archive containing a manifest and
+// the puzzle files it lists.
+type LevelPack struct {
+	Path     string
+	Manifest *PackManifest
+	Levels   []*crunch.Puzzle
+}
+
+// Compatible returns true if the pack can be played by this version of the
+// game.
+func (p *LevelPack) Compatible() bool {
+	if p.Manifest.MinGameVersion == "" {
+		return true
+	}
+	cmp, err := compareVersions(GameVersion, p.Manifest.MinGameVersion)
+	return err == nil && cmp >= 0
+}
+
+// Level returns the index of the level with the given id or -1 if the pack has
+// no such level.
+func (p *LevelPack) Level(id string) int {
+	for i, puzzle := range p.Levels {
+		if puzzle.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// ReadLevelPack reads the level pack at path, which may be a directory or a
+// zip archive.
+func ReadLevelPack(path string) (*LevelPack, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return readLevelPack(path, func(name string) (io.ReadCloser, error) {
+			return os.Open(filepath.Join(path, filepath.FromSlash(name)))
+		})
+	}
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid level pack %v: %v", path, err)
+	}
+	defer archive.Close()
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+	return readLevelPack(path, func(name string) (io.ReadCloser, error) {
+		f, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: not found in archive", name)
+		}
+		return f.Open()
+	})
+}
+
+func readLevelPack(root string, open func(name string) (io.ReadCloser, error)) (*LevelPack, error) {
+	pack := &LevelPack{Path: root}
+
+	r, err := open(PackManifestName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid level pack %v: %v", root, err)
+	}
+	err = json.NewDecoder(r).Decode(&pack.Manifest)
+	r.Close()
+	if err != nil {
+		return nil, fmt.Errorf("invalid level pack %v: %v", root, err)
+	}
+	err = pack.Manifest.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid level pack %v: %v", root, err)
+	}
+
+	ids := make(map[string]bool)
+	for _, name := range pack.Manifest.Levels {
+		r, err := open(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid level pack %v: %v", root, err)
+		}
+		puzzle, err := crunch.ReadPuzzle(r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid level pack %v: %s: %v", root, name, err)
+		}
+		if ids[puzzle.ID] {
+			return nil, fmt.Errorf("invalid level pack %v: %s: duplicate puzzle id %s", root, name, puzzle.ID)
+		}
+		ids[puzzle.ID] = true
+		pack.Levels = append(pack.Levels, puzzle)
+	}
+
+	return pack, nil
+}
+
+func (m *PackManifest) validate() error {
+	if m == nil {
+		return fmt.Errorf("no manifest defined")
+	}
+	if m.Name == "" {
+		return fmt.Errorf("pack has no name")
+	}
+	if m.MinGameVersion != "" {
+		_, err := parseVersion(m.MinGameVersion)
+		if err != nil {
+			return err
+		}
+	}
+	if len(m.Levels) == 0 {
+		return fmt.Errorf("pack has no levels")
+	}
+	for _, name := range m.Levels {
+		// Level files must stay inside the pack.
+		clean := path.Clean(name)
+		if clean != name || path.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("invalid level path: %q", name)
+		}
+	}
+	return nil
+}
+
+// FindLevelPacks returns the level packs installed in dir sorted by name.
+// Packs that cannot be read are logged and skipped, as are packs with the same
+// name as a pack already found.
+func FindLevelPacks(dir string) ([]*LevelPack, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var packs []*LevelPack
+	names := make(map[string]string)
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) != ".zip" {
+			continue
+		}
+		pack, err := ReadLevelPack(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		if other, ok := names[pack.Manifest.Name]; ok {
+			log.Printf("pack=%q path=%s skipping pack with the same name as %s", pack.Manifest.Name, pack.Path, other)
+			continue
+		}
+		names[pack.Manifest.Name] = pack.Path
+		packs = append(packs, pack)
+	}
+
+	sort.Slice(packs, func(i, j int) bool {
+		return packs[i].Manifest.Name < packs[j].Manifest.Name
+	})
+
+	return packs, nil
+}
+
+// parseVersion parses a version of the form "v1.2.3".  The leading "v" is
+// optional, missing components are zero, and pre-release suffixes such as
+// "-beta" are ignored.
+func parseVersion(v string) ([3]int, error) {
+	var parts [3]int
+	s := strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	fields := strings.Split(s, ".")
+	if len(fields) > len(parts) {
+		return parts, fmt.Errorf("invalid version: %q", v)
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, fmt.Errorf("invalid version: %q", v)
+		}
+		parts[i] = n
+	}
+	return parts, nil
+}
+
+// compareVersions returns -1, 0, or 1 if version a is older than, the same
+// as, or newer than version b.
+func compareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range va {
+		switch {
+		case va[i] < vb[i]:
+			return -1, nil
+		case va[i] > vb[i]:
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, test := range []struct {
+		a, b  string
+		cmp   int
+		valid bool
+	}{
+		{"v1.0.0", "v0.0.1", 1, true},
+		{"v0.0.1", "v1.0.0", -1, true},
+		{"1.2", "v1.2.0", 0, true},
+		{"v1.2.3-beta", "v1.2.3", 0, true},
+		{"v1.2.3+build", "v1.2.4", -1, true},
+		{"v1.10", "v1.9", 1, true},
+		{"v1", "v0.99.99", 1, true},
+		{"v1.x", "v1.0.0", 0, false},
+		{"v1.0.0", "v1.2.3.4", 0, false},
+		{"v-1", "v1.0.0", 0, false},
+		{"", "v1.0.0", 0, false},
+	} {
+		cmp, err := compareVersions(test.a, test.b)
+		if (err == nil) != test.valid {
+			t.Errorf("%q %q: error %v", test.a, test.b, err)
+			continue
+		}
+		if cmp != test.cmp {
+			t.Errorf("%q %q: %d (expected %d)", test.a, test.b, cmp, test.cmp)
+		}
+	}
+}
+
+func TestPackManifestValidate(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		manifest *PackManifest
+		valid    bool
+	}{
+		{"valid", &PackManifest{Name: "a", MinGameVersion: "v0.1", Levels: []string{"a.json", "more/b.json"}}, true},
+		{"nil", nil, false},
+		{"no name", &PackManifest{Levels: []string{"a.json"}}, false},
+		{"bad version", &PackManifest{Name: "a", MinGameVersion: "latest", Levels: []string{"a.json"}}, false},
+		{"no levels", &PackManifest{Name: "a"}, false},
+		{"parent", &PackManifest{Name: "a", Levels: []string{"../a.json"}}, false},
+		{"hidden parent", &PackManifest{Name: "a", Levels: []string{"more/../../a.json"}}, false},
+		{"absolute", &PackManifest{Name: "a", Levels: []string{"/a.json"}}, false},
+		{"unclean", &PackManifest{Name: "a", Levels: []string{"./a.json"}}, false},
+	} {
+		err := test.manifest.validate()
+		if (err == nil) != test.valid {
+			t.Errorf("%s: error %v", test.name, err)
+		}
+	}
+}
+
+var testPackFiles = map[string]string{
+	"pack.json":       `{"Name": "Testo", "Version": "v1.0.0", "Levels": ["unua.json", "pli/dua.json"]}`,
+	"unua.json":       `{"ID": "unua", "Moves": 2, "Vines": [["Large:2"], ["Small:0"]]}`,
+	"pli/dua.json":    `{"ID": "dua", "Moves": 2, "Vines": [["Small:0"], ["Gnat"]]}`,
+	"pli/ekstra.json": `{"ID": "ekstra", "Moves": 2, "Vines": [["Small:0"]]}`,
+}
+
+// writeTestPack writes files as a directory and as a zip archive in dir.
+func writeTestPack(t *testing.T, dir, name string, files map[string]string) (string, string) {
+	root := filepath.Join(dir, name)
+	archive, err := os.Create(root + ".zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	zw := zip.NewWriter(archive)
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), 0775)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(path, []byte(content), 0664)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = w.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, root + ".zip"
+}
+
+func tempTestDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "cimoj-packs-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestReadLevelPack(t *testing.T) {
+	root, archive := writeTestPack(t, tempTestDir(t), "testo", testPackFiles)
+	for _, path := range []string{root, archive} {
+		pack, err := ReadLevelPack(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if pack.Manifest.Name != "Testo" || len(pack.Levels) != 2 {
+			t.Fatalf("%s: pack %q of %d levels", path, pack.Manifest.Name, len(pack.Levels))
+		}
+		// Levels are in the order of the manifest.
+		if pack.Level("unua") != 0 || pack.Level("dua") != 1 || pack.Level("ekstra") != -1 {
+			t.Fatalf("%s: levels out of order", path)
+		}
+		if !pack.Compatible() {
+			t.Fatalf("%s: pack is incompatible", path)
+		}
+	}
+}
+
+func TestReadLevelPackInvalid(t *testing.T) {
+	dir := tempTestDir(t)
+	for _, test := range []struct {
+		name  string
+		files map[string]string
+	}{
+		{"no manifest", map[string]string{"unua.json": testPackFiles["unua.json"]}},
+		{"bad manifest", map[string]string{"pack.json": `{"Name": "Testo", "Levels": ["../unua.json"]}`}},
+		{"missing level", map[string]string{"pack.json": testPackFiles["pack.json"], "unua.json": testPackFiles["unua.json"]}},
+		{"bad level", map[string]string{"pack.json": `{"Name": "Testo", "Levels": ["unua.json"]}`, "unua.json": `{"ID": "unua"}`}},
+		{"duplicate id", map[string]string{"pack.json": `{"Name": "Testo", "Levels": ["unua.json", "alia.json"]}`, "unua.json": testPackFiles["unua.json"], "alia.json": testPackFiles["unua.json"]}},
+	} {
+		root, archive := writeTestPack(t, dir, filepath.Base(dir)+"-"+test.name, test.files)
+		for _, path := range []string{root, archive} {
+			_, err := ReadLevelPack(path)
+			if err == nil {
+				t.Errorf("%s: %s read", test.name, filepath.Base(path))
+			}
+		}
+	}
+}
+
+func TestFindLevelPacks(t *testing.T) {
+	dir := tempTestDir(t)
+	writeTestPack(t, dir, "testo", testPackFiles)
+	files := map[string]string{
+		"pack.json": `{"Name": "Alia", "MinGameVersion": "v999", "Levels": ["unua.json"]}`,
+		"unua.json": testPackFiles["unua.json"],
+	}
+	writeTestPack(t, dir, "alia", files)
+	err := ioutil.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0664)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Mkdir(filepath.Join(dir, "empty"), 0775)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each pack is found once, though its directory and archive are both
+	// installed.
+	packs, err := FindLevelPacks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packs) != 2 || packs[0].Manifest.Name != "Alia" || packs[1].Manifest.Name != "Testo" {
+		t.Fatalf("found %d packs", len(packs))
+	}
+	if packs[0].Compatible() {
+		t.Fatal("a pack for a future version is compatible")
+	}
+}
+
+func TestShippedLevelPack(t *testing.T) {
+	pack, err := ReadLevelPack("levels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pack.Levels) != len(pack.Manifest.Levels) || !pack.Compatible() {
+		t.Fatalf("%d levels, compatible %v", len(pack.Levels), pack.Compatible())
+	}
+}
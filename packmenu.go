@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/JoelOtter/termloop"
+)
+
+// PackMenu lists the levels of a LevelPack along with the player's progress
+// through them.
+type PackMenu struct {
+	pack         *LevelPack
+	progress     *PackProgress
+	menu         *simpleMenu
+	textAttempts *termloop.Text
+	textSolved   *termloop.Text
+	textBest     *termloop.Text
+	level        *termloop.BaseLevel
+}
+
+// NewPackMenu creates a menu for choosing a level of pack.
+func NewPackMenu(pack *LevelPack, progress *PackProgress) *PackMenu {
+	m := &PackMenu{
+		pack:     pack,
+		progress: progress,
+	}
+	fg := termloop.ColorWhite
+	bg := termloop.ColorBlack
+
+	m.level = termloop.NewBaseLevel(termloop.Cell{
+		Fg: fg,
+		Bg: bg,
+		Ch: ' ',
+	})
+
+	manifest := pack.Manifest
+	m.level.AddEntity(termloop.NewText(2, 1, manifest.Name, fg|termloop.AttrBold, bg))
+	m.level.AddEntity(termloop.NewText(2, 2, fmt.Sprintf("de %s, %s", manifest.Author, manifest.Version), fg, bg))
+	m.level.AddEntity(termloop.NewText(2, 3, fmt.Sprintf("Solvitaj: %d/%d", progress.NumSolved(pack), len(pack.Levels)), fg, bg))
+
+	choices := make([]string, len(pack.Levels))
+	for i, puzzle := range pack.Levels {
+		name := puzzle.Name
+		if name == "" {
+			name = puzzle.ID
+		}
+		choices[i] = fmt.Sprintf("%2d. %s", i+1, name)
+		switch {
+		case progress.Level(puzzle.ID).Solved:
+			choices[i] += " ✓"
+		case !progress.Unlocked(pack, i):
+			choices[i] += " (ŝlosita)"
+		}
+	}
+	m.menu = newSimpleMenu(4, 5, fg, bg, choices)
+	m.level.AddEntity(m.menu)
+
+	stats := termloop.NewBaseLevel(termloop.Cell{})
+	m.level.AddEntity(stats)
+	stats.SetOffset(40, 5)
+
+	stats.AddEntity(termloop.NewText(0, 0, "Provoj:", fg, bg))
+	m.textAttempts = termloop.NewText(14, 0, "", fg, bg)
+	stats.AddEntity(m.textAttempts)
+
+	stats.AddEntity(termloop.NewText(0, 2, "Solvita:", fg, bg))
+	m.textSolved = termloop.NewText(14, 2, "", fg, bg)
+	stats.AddEntity(m.textSolved)
+
+	stats.AddEntity(termloop.NewText(0, 4, "Plej Bona:", fg, bg))
+	m.textBest = termloop.NewText(14, 4, "", fg, bg)
+	stats.AddEntity(m.textBest)
+
+	// Start on the first level that has not been solved.
+	sel := 0
+	for sel < len(pack.Levels)-1 && progress.Level(pack.Levels[sel].ID).Solved {
+		sel++
+	}
+	m.menu.SetSelection(sel, true)
+	m.updateStats()
+
+	return m
+}
+
+// GetSelection returns the index of the selected level and whether it may be
+// played.
+func (m *PackMenu) GetSelection() (int, bool) {
+	i, _ := m.menu.GetSelection()
+	if i < 0 {
+		return -1, false
+	}
+	return i, m.progress.Unlocked(m.pack, i)
+}
+
+func (m *PackMenu) updateStats() {
+	i, _ := m.menu.GetSelection()
+	if i < 0 {
+		return
+	}
+	lp := m.progress.Level(m.pack.Levels[i].ID)
+	m.textAttempts.SetText(fmt.Sprint(lp.Attempts))
+	if !lp.Solved {
+		m.textSolved.SetText("ne")
+		m.textBest.SetText("-")
+		return
+	}
+	m.textSolved.SetText("jes")
+	m.textBest.SetText(fmt.Sprintf("%d (%d movoj)", lp.BestScore, lp.BestMoves))
+}
+
+// Draw implements termloop.Drawable
+func (m *PackMenu) Draw(screen *termloop.Screen) {
+	m.level.Draw(screen)
+}
+
+// Tick implements termloop.Drawable
+func (m *PackMenu) Tick(event termloop.Event) {
+	if event.Type == termloop.EventKey {
+		switch event.Ch {
+		case 'k':
+			m.menu.SetSelection(-1, false)
+		case 'j':
+			m.menu.SetSelection(1, false)
+		default:
+			return
+		}
+		m.updateStats()
+	}
+}
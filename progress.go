@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// LevelProgress is a player's record for a single level of a LevelPack.  The
+// best score and best moves only count attempts that solved the level.
+type LevelProgress struct {
+	Attempts  int
+	Solved    bool
+	BestScore int64
+	BestMoves int
+}
+
+// PackProgress is a player's record for the levels of a LevelPack, keyed by
+// puzzle id.
+type PackProgress struct {
+	Levels map[string]*LevelProgress
+}
+
+// Level returns the progress of the level with the given id.  A zero
+// LevelProgress is returned for levels that have not been attempted.
+func (p *PackProgress) Level(id string) LevelProgress {
+	if p == nil || p.Levels[id] == nil {
+		return LevelProgress{}
+	}
+	return *p.Levels[id]
+}
+
+// NumSolved returns the number of levels in pack that have been solved.
+func (p *PackProgress) NumSolved(pack *LevelPack) int {
+	n := 0
+	for _, puzzle := range pack.Levels {
+		if p.Level(puzzle.ID).Solved {
+			n++
+		}
+	}
+	return n
+}
+
+// Unlocked returns true if level i of pack may be played.  The first level is
+// always unlocked and each following level is unlocked by solving the one
+// before it.
+func (p *PackProgress) Unlocked(pack *LevelPack, i int) bool {
+	return i == 0 || p.Level(pack.Levels[i-1].ID).Solved
+}
+
+// ProgressFile tracks the progress of players through level packs in a json
+// file.  Progress is keyed by player and then by pack name.  ProgressFile is
+// safe to use from multiple goroutines.
+type ProgressFile struct {
+	path    string
+	mut     sync.Mutex
+	players map[string]map[string]*PackProgress
+}
+
+// OpenProgressFile returns a ProgressFile which stores progress in path.  The
+// file is created when progress is first recorded.
+func OpenProgressFile(path string) (*ProgressFile, error) {
+	pf := &ProgressFile{
+		path:    path,
+		players: make(map[string]map[string]*PackProgress),
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return pf, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	err = json.NewDecoder(f).Decode(&pf.players)
+	if err != nil {
+		return nil, fmt.Errorf("invalid progress file %v: %v", path, err)
+	}
+	return pf, nil
+}
+
+// Progress returns a copy of player's progress through the named pack.
+func (pf *ProgressFile) Progress(player, pack string) *PackProgress {
+	pf.mut.Lock()
+	defer pf.mut.Unlock()
+
+	progress := &PackProgress{Levels: make(map[string]*LevelProgress)}
+	stored := pf.players[player][pack]
+	if stored == nil {
+		return progress
+	}
+	for id, level := range stored.Levels {
+		lp := *level
+		progress.Levels[id] = &lp
+	}
+	return progress
+}
+
+// Record adds an attempt at a level to player's progress and persists the
+// progress of every player.
+func (pf *ProgressFile) Record(player, pack, level string, solved bool, score int64, moves int) error {
+	pf.mut.Lock()
+	defer pf.mut.Unlock()
+
+	packs := pf.players[player]
+	if packs == nil {
+		packs = make(map[string]*PackProgress)
+		pf.players[player] = packs
+	}
+	progress := packs[pack]
+	if progress == nil {
+		progress = &PackProgress{Levels: make(map[string]*LevelProgress)}
+		packs[pack] = progress
+	}
+	lp := progress.Levels[level]
+	if lp == nil {
+		lp = &LevelProgress{}
+		progress.Levels[level] = lp
+	}
+
+	lp.Attempts++
+	if solved {
+		if !lp.Solved || score > lp.BestScore {
+			lp.BestScore = score
+		}
+		if !lp.Solved || moves < lp.BestMoves {
+			lp.BestMoves = moves
+		}
+		lp.Solved = true
+	}
+
+	return pf.write()
+}
+
+func (pf *ProgressFile) write() error {
+	// Write to a temporary file first so a failed write cannot wipe out
+	// existing progress.
+	tmp := pf.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0664)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(f).Encode(pf.players)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, pf.path)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestProgressFile(t *testing.T) {
+	path := filepath.Join(tempTestDir(t), "cimoj-progress.json")
+	pf, err := OpenProgressFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pack, err := ReadLevelPack("levels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := pack.Manifest.Name
+	first, second := pack.Levels[0].ID, pack.Levels[1].ID
+
+	progress := pf.Progress("ana", name)
+	if !progress.Unlocked(pack, 0) || progress.Unlocked(pack, 1) {
+		t.Fatal("levels unlocked before any were played")
+	}
+	for _, attempt := range []struct {
+		player string
+		level  string
+		solved bool
+		score  int64
+		moves  int
+	}{
+		{"ana", first, false, 900, 1},
+		{"ana", first, true, 100, 4},
+		{"ana", first, true, 300, 3},
+		{"ana", first, true, 200, 2},
+		{"bo", second, true, 50, 2},
+	} {
+		err := pf.Record(attempt.player, name, attempt.level, attempt.solved, attempt.score, attempt.moves)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Progress is read back from the file.
+	pf, err = OpenProgressFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress = pf.Progress("ana", name)
+	want := LevelProgress{Attempts: 4, Solved: true, BestScore: 300, BestMoves: 2}
+	if progress.Level(first) != want {
+		t.Fatalf("progress %+v (expected %+v)", progress.Level(first), want)
+	}
+	if progress.Level(second) != (LevelProgress{}) || progress.NumSolved(pack) != 1 || !progress.Unlocked(pack, 1) {
+		t.Fatalf("progress %+v", progress)
+	}
+	if pf.Progress("bo", name).NumSolved(pack) != 1 || pf.Progress("cy", name).NumSolved(pack) != 0 {
+		t.Fatal("progress of other players is mixed up")
+	}
+
+	// The copy returned by Progress is not the stored progress.
+	progress.Levels[first].Attempts = 0
+	if pf.Progress("ana", name).Level(first).Attempts != 4 {
+		t.Fatal("stored progress changed")
+	}
+}
+
+func TestProgressFileInvalid(t *testing.T) {
+	path := filepath.Join(tempTestDir(t), "cimoj-progress.json")
+	err := ioutil.WriteFile(path, []byte(`{"ana": `), 0664)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = OpenProgressFile(path)
+	if err == nil {
+		t.Fatal("corrupt progress opened")
+	}
+}
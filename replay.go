@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/JoelOtter/termloop"
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+// Replay is a recording of a game.  Because games are deterministic the
+// seed, the board configuration, and the player's input are enough to
+// reproduce a game exactly.
+type Replay struct {
+	GameVersion string
+	GameType    string
+	Difficulty  string        `json:",omitempty"`
+	ChainStep   time.Duration `json:",omitempty"`
+	Player      string
+	Seed        int64
+	NumCol      int
+	ColDepth    int
+	Start       time.Time
+	End         int64  // the tick at which the game ended
+	Hash        uint64 `json:",omitempty"` // the hash of the board at End
+	Score       int64
+	Level       int
+	Puzzle      *crunch.Puzzle `json:",omitempty"`
+	Inputs      []ReplayInput
+}
+
+// ReplayInput is a player control applied at a tick of the game clock.  Ticks
+// are crunch.TickDuration apart.
+type ReplayInput struct {
+	Tick    int64
+	Control crunch.PlayerControl
+}
+
+// ReadReplay reads a replay from the file at path.
+func ReadReplay(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r *Replay
+	err = json.NewDecoder(f).Decode(&r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid replay %v: %v", path, err)
+	}
+	return r, nil
+}
+
+// WriteReplay writes r to a file at path.
+func WriteReplay(path string, r *Replay) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0664)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(f).Encode(r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// useDifficulty configures conf with the difficulty the replay was recorded
+// with.  A survival game cannot be reproduced under another difficulty, so an
+// error is returned if the replay does not name a known difficulty.
+func (r *Replay) useDifficulty(conf *CrunchConfig) error {
+	if r.Puzzle != nil {
+		return nil
+	}
+	d := lookupDifficulty(r.Difficulty)
+	if d == nil {
+		return fmt.Errorf("unknown difficulty %q", r.Difficulty)
+	}
+	conf.Difficulty = d.Name
+	conf.Survival = d
+	return nil
+}
+
+func (r *Replay) crunchConfig(survival crunch.SurvivalDifficulty) *crunch.Config {
+	return &crunch.Config{
+		NumCol:    r.NumCol,
+		ColDepth:  r.ColDepth,
+		Survival:  survival,
+		Seed:      r.Seed,
+		Puzzle:    r.Puzzle,
+		ChainStep: r.ChainStep,
+	}
+}
+
+// Simulate plays the replay without a terminal and returns the finished game.
+func (r *Replay) Simulate(survival crunch.SurvivalDifficulty) *crunch.Game {
+	game := crunch.NewGame(r.crunchConfig(survival))
+	var input []crunch.PlayerControl
+	for k := 0; k < len(r.Inputs); {
+		tick := r.Inputs[k].Tick
+		input = input[:0]
+		for ; k < len(r.Inputs) && r.Inputs[k].Tick == tick; k++ {
+			input = append(input, r.Inputs[k].Control)
+		}
+		game.Step(input, time.Duration(tick-game.Ticks())*crunch.TickDuration)
+	}
+	if game.Ticks() < r.End {
+		game.Step(nil, time.Duration(r.End-game.Ticks())*crunch.TickDuration)
+	}
+	return game
+}
+
+// Verify simulates the replay and returns an error if the result does not
+// match the recording.  The game must end by the recorded tick with the
+// recorded score and level, and the board must match the recorded hash.
+// Replays recorded without a hash are only checked by score and level.
+func (r *Replay) Verify(survival crunch.SurvivalDifficulty) error {
+	game := r.Simulate(survival)
+	if game.Score() != r.Score || game.Level() != r.Level {
+		return fmt.Errorf("replay does not reproduce its score: recorded %d (level %d) simulated %d (level %d)",
+			r.Score, r.Level, game.Score(), game.Level())
+	}
+	if !game.Over() || game.Ticks() != r.End {
+		return fmt.Errorf("replay does not end at tick %d", r.End)
+	}
+	if r.Hash != 0 && game.Hash() != r.Hash {
+		return fmt.Errorf("replay does not reproduce its board: recorded hash %x simulated %x", r.Hash, game.Hash())
+	}
+	return nil
+}
+
+// replayRates are the playback speeds cycled through when fast-forwarding.
+var replayRates = []float64{1, 2, 4, 8}
+
+// replayer drives a CrunchGame from a Replay instead of the keyboard.
+type replayer struct {
+	replay *Replay
+	clock  *crunch.ScaledClock
+	next   int
+	rate   int
+	paused bool
+	played time.Duration
+	input  []crunch.PlayerControl
+}
+
+// playReplay makes g play back r instead of accepting player input.  The game
+// is reset to the beginning of the replay.
+func (g *CrunchGame) playReplay(r *Replay) {
+	g.engine = crunch.NewGame(r.crunchConfig(g.config.Survival))
+	g.scoreDB = nil
+	g.replay = &replayer{
+		replay: r,
+		clock:  crunch.NewScaledClock(g.clock, replayRates[0]),
+	}
+	g.clock = g.replay.clock
+	g.lastStep = g.clock.Now()
+	g.updateTextReplay()
+}
+
+// stepReplay feeds the engine all recorded input that occurs within the next
+// dt of the game clock.
+func (g *CrunchGame) stepReplay(dt time.Duration) {
+	rp := g.replay
+	target := rp.played + dt
+	inputs := rp.replay.Inputs
+	for rp.next < len(inputs) {
+		tick := inputs[rp.next].Tick
+		at := time.Duration(tick) * crunch.TickDuration
+		if at > target {
+			break
+		}
+		rp.input = rp.input[:0]
+		for ; rp.next < len(inputs) && inputs[rp.next].Tick == tick; rp.next++ {
+			rp.input = append(rp.input, inputs[rp.next].Control)
+		}
+		g.handleEvents(g.engine.Step(rp.input, at-rp.played))
+		rp.played = at
+	}
+	g.handleEvents(g.engine.Step(nil, target-rp.played))
+	rp.played = target
+}
+
+// tickReplay handles the playback controls of a replay.  Playback can be
+// paused, fast-forwarded, and stepped to the next recorded input while paused.
+func (g *CrunchGame) tickReplay(event termloop.Event) {
+	if event.Type != termloop.EventKey {
+		return
+	}
+	rp := g.replay
+	switch {
+	case event.Key == termloop.KeySpace || event.Ch == 'p':
+		rp.paused = !rp.paused
+	case event.Ch == 'f':
+		rp.rate = (rp.rate + 1) % len(replayRates)
+	case event.Ch == 'n':
+		if !rp.paused {
+			return
+		}
+		step := time.Second
+		if rp.next < len(rp.replay.Inputs) {
+			step = time.Duration(rp.replay.Inputs[rp.next].Tick)*crunch.TickDuration - rp.played
+		}
+		rp.clock.Advance(step)
+	default:
+		return
+	}
+	if rp.paused {
+		rp.clock.SetRate(0)
+	} else {
+		rp.clock.SetRate(replayRates[rp.rate])
+	}
+	g.updateTextReplay()
+}
+
+func (g *CrunchGame) updateTextReplay() {
+	rp := g.replay
+	if rp.paused {
+		g.textReplay.SetText("Ripeto: paŭzita")
+		return
+	}
+	g.textReplay.SetText(fmt.Sprintf("Ripeto: %gx", replayRates[rp.rate]))
+}
+
+// recordInput appends input applied to the engine to the game's recording.
+func (g *CrunchGame) recordInput(input []crunch.PlayerControl) {
+	tick := g.engine.Ticks()
+	for _, pctl := range input {
+		g.recording.Inputs = append(g.recording.Inputs, ReplayInput{
+			Tick:    tick,
+			Control: pctl,
+		})
+	}
+}
+
+func (g *CrunchGame) calcReplay() *Replay {
+	r := g.recording
+	r.GameVersion = GameVersion
+	r.GameType = g.config.gameType()
+	r.Difficulty = g.config.Difficulty
+	r.ChainStep = g.engine.Config().ChainStep
+	r.Player = g.config.Player
+	r.Seed = g.engine.Seed()
+	r.NumCol = g.config.NumCol
+	r.ColDepth = g.config.ColDepth
+	r.Start = g.startTime
+	r.End = g.engine.Ticks()
+	r.Hash = g.engine.Hash()
+	r.Score = g.engine.Score()
+	r.Level = g.engine.Level()
+	r.Puzzle = g.config.Puzzle
+	return r
+}
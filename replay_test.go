@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bmatsuo/cimoj/bot"
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+func TestMain(m *testing.M) {
+	// The engine logs every move.
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+// recordTestReplay records a game played by a bot the way a CrunchGame
+// records its player.
+func recordTestReplay(t *testing.T, seed int64) *Replay {
+	d := lookupDifficulty(defaultDifficulty)
+	r := &Replay{
+		GameVersion: GameVersion,
+		GameType:    "survival",
+		Difficulty:  d.Name,
+		Player:      "tester",
+		Seed:        seed,
+		NumCol:      6,
+		ColDepth:    7,
+		Start:       time.Unix(1000, 0).UTC(),
+	}
+	g := crunch.NewGame(r.crunchConfig(d))
+	p := bot.Lookup("greedy").New(seed)
+	for !g.Over() {
+		input := p.Play(g.Snapshot())
+		g.Step(input, crunch.TickDuration)
+		for _, ctl := range input {
+			r.Inputs = append(r.Inputs, ReplayInput{Tick: g.Ticks(), Control: ctl})
+		}
+	}
+	// The front end notices the end of the game a little after it happens.
+	g.Step(nil, 5*crunch.TickDuration)
+	r.End = g.Ticks()
+	r.Hash = g.Hash()
+	r.Score = g.Score()
+	r.Level = g.Level()
+	if len(r.Inputs) == 0 || r.Score == 0 {
+		t.Fatalf("seed %d: the bot did not play", seed)
+	}
+	return r
+}
+
+func TestReplayRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cimoj-replay-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := recordTestReplay(t, 3)
+	path := filepath.Join(dir, "replay.json")
+	err = WriteReplay(path, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadReplay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Seed != r.Seed || read.End != r.End || read.Hash != r.Hash || len(read.Inputs) != len(r.Inputs) {
+		t.Fatalf("replay changed when written: %+v", read)
+	}
+	config := &CrunchConfig{}
+	err = read.useDifficulty(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = read.Verify(config.Survival)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayVerifyMismatch(t *testing.T) {
+	survival := lookupDifficulty(defaultDifficulty)
+	for _, test := range []struct {
+		name   string
+		change func(r *Replay)
+	}{
+		{"score", func(r *Replay) { r.Score++ }},
+		{"hash", func(r *Replay) { r.Hash++ }},
+		{"end", func(r *Replay) { r.End-- }},
+		{"input", func(r *Replay) { r.Inputs = r.Inputs[1:] }},
+	} {
+		r := recordTestReplay(t, 3)
+		test.change(r)
+		if r.Verify(survival) == nil {
+			t.Errorf("%s: changed replay verified", test.name)
+		}
+	}
+}
+
+func TestReplayUnknownDifficulty(t *testing.T) {
+	r := &Replay{Difficulty: "impossible"}
+	config := &CrunchConfig{Difficulty: defaultDifficulty}
+	if r.useDifficulty(config) == nil {
+		t.Fatal("unknown difficulty used")
+	}
+	if config.Difficulty != defaultDifficulty {
+		t.Fatalf("config changed to %q", config.Difficulty)
+	}
+
+	// Puzzles are played the same at any difficulty.
+	r.Puzzle = &crunch.Puzzle{}
+	if r.useDifficulty(config) != nil {
+		t.Fatal("puzzle replay rejected")
+	}
+}
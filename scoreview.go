@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/JoelOtter/termloop"
+)
+
+// scoreViewMax is the maximum number of scores listed by a HighScoreView.
+const scoreViewMax = 100
+
+// scoreViewRows is the number of scores visible at one time.
+const scoreViewRows = 12
+
+// gameTypes are the game types that can be shown in a HighScoreView along
+// with their display names.
+var gameTypes = []struct {
+	Type string
+	Name string
+}{
+	{"survival", "Supervivo"},
+	{"puzzle", "Enigmo"},
+	{"versus", "Kontraŭ"},
+}
+
+// HighScoreView is a leaderboard of the scores in a ScoreDB.  Scores can be
+// filtered by game type, player, and game version.
+type HighScoreView struct {
+	config     *CrunchConfig
+	scoreDB    ScoreDB
+	gameType   int
+	onlyMe     bool
+	versions   []string
+	version    int // index into versions, 0 is all versions
+	scores     []*HighScore
+	sel        int
+	top        int
+	textFilter *termloop.Text
+	textRows   [scoreViewRows]*termloop.Text
+	textStatus *termloop.Text
+	level      *termloop.BaseLevel
+}
+
+// NewHighScoreView creates a leaderboard of the scores in db.
+func NewHighScoreView(config *CrunchConfig, db ScoreDB) *HighScoreView {
+	v := &HighScoreView{
+		config:  config,
+		scoreDB: db,
+	}
+	fg := termloop.ColorWhite
+	bg := termloop.ColorBlack
+
+	v.level = termloop.NewBaseLevel(termloop.Cell{
+		Fg: fg,
+		Bg: bg,
+		Ch: ' ',
+	})
+
+	v.level.AddEntity(termloop.NewText(2, 1, "Plej Altaj Poentoj", fg|termloop.AttrBold, bg))
+	v.textFilter = termloop.NewText(2, 3, "", termloop.ColorGreen, bg)
+	v.level.AddEntity(v.textFilter)
+	header := fmt.Sprintf("    %-4s %-12s %8s %-10s %-16s %8s", "", "Ludanto", "Poentoj", "Etaĝo", "Dato", "Daŭro")
+	v.level.AddEntity(termloop.NewText(2, 5, header, termloop.ColorGreen, bg))
+	for i := range v.textRows {
+		v.textRows[i] = termloop.NewText(2, 6+i, "", fg, bg)
+		v.level.AddEntity(v.textRows[i])
+	}
+	v.textStatus = termloop.NewText(2, 7+scoreViewRows, "", fg, bg)
+	v.level.AddEntity(v.textStatus)
+	help := "t: reĝimo  p: ludanto  v: versio  j/k: movu  Esc: reen"
+	v.level.AddEntity(termloop.NewText(2, 9+scoreViewRows, help, termloop.ColorCyan, bg))
+
+	v.load()
+
+	return v
+}
+
+// load queries the ScoreDB using the current filters.
+func (v *HighScoreView) load() {
+	gameType := gameTypes[v.gameType].Type
+	player := ""
+	if v.onlyMe {
+		player = v.config.Player
+	}
+
+	// The versions that can be chosen are those of the scores matching the
+	// other filters.
+	all, err := v.scoreDB.TopHighScores(scoreViewMax, gameType, player)
+	if err != nil {
+		log.Printf("unable to read high scores: %v", err)
+	}
+	current := ""
+	if v.version < len(v.versions) {
+		current = v.versions[v.version]
+	}
+	v.versions = []string{""}
+	v.version = 0
+	seen := make(map[string]bool)
+	for _, score := range all {
+		version := score.Qual["GameVersion"]
+		if version == "" || seen[version] {
+			continue
+		}
+		seen[version] = true
+		if version == current {
+			v.version = len(v.versions)
+		}
+		v.versions = append(v.versions, version)
+	}
+
+	v.scores = all
+	if v.version > 0 {
+		v.scores, err = v.scoreDB.TopHighScores(scoreViewMax, gameType, player, "GameVersion", v.versions[v.version])
+		if err != nil {
+			log.Printf("unable to read high scores: %v", err)
+		}
+	}
+	v.sel = 0
+	v.top = 0
+
+	switch {
+	case err != nil:
+		v.textStatus.SetText(fmt.Sprintf("Eraro: %v", err))
+	case len(v.scores) == 0:
+		v.textStatus.SetText("Neniuj poentoj.")
+	default:
+		v.textStatus.SetText("")
+	}
+	v.update()
+}
+
+func (v *HighScoreView) update() {
+	player := "ĉiuj"
+	if v.onlyMe {
+		player = v.config.Player
+	}
+	version := "ĉiuj"
+	if v.version > 0 {
+		version = v.versions[v.version]
+	}
+	v.textFilter.SetText(fmt.Sprintf("Reĝimo: %s   Ludanto: %s   Versio: %s", gameTypes[v.gameType].Name, player, version))
+
+	for i := range v.textRows {
+		k := v.top + i
+		if k >= len(v.scores) {
+			v.textRows[i].SetText("")
+			continue
+		}
+		arrow := " "
+		fg := termloop.ColorWhite
+		if k == v.sel {
+			arrow = "»"
+			fg |= termloop.AttrUnderline
+		}
+		v.textRows[i].SetText(arrow + " " + formatHighScore(k+1, v.scores[k]))
+		v.textRows[i].SetColor(fg, termloop.ColorBlack)
+	}
+}
+
+func formatHighScore(rank int, score *HighScore) string {
+	level := fmt.Sprint(score.Level)
+	if id, ok := score.Qual["Level"]; ok {
+		// Puzzles are identified by their id rather than a level number.
+		level = id
+	}
+	if opponent, ok := score.Qual["Opponent"]; ok {
+		// Versus games show who was played and whether they were beaten.
+		mark := "✗"
+		if score.Qual["Won"] == "true" {
+			mark = "✓"
+		}
+		level = mark + " " + opponent
+	}
+	date := score.Start.Local().Format("2006-01-02 15:04")
+	duration := score.End.Sub(score.Start)
+	if duration < 0 {
+		duration = 0
+	}
+	return fmt.Sprintf("%3d. %-12.12s %8d %-10.10s %-16s %8s", rank, score.Player, score.Score, level, date, duration.Round(time.Second))
+}
+
+func (v *HighScoreView) moveSelection(delta int) {
+	v.sel += delta
+	if v.sel >= len(v.scores) {
+		v.sel = len(v.scores) - 1
+	}
+	if v.sel < 0 {
+		v.sel = 0
+	}
+	if v.sel < v.top {
+		v.top = v.sel
+	} else if v.sel >= v.top+scoreViewRows {
+		v.top = v.sel - scoreViewRows + 1
+	}
+	v.update()
+}
+
+// Draw implements termloop.Drawable
+func (v *HighScoreView) Draw(screen *termloop.Screen) {
+	v.level.Draw(screen)
+}
+
+// Tick implements termloop.Drawable
+func (v *HighScoreView) Tick(event termloop.Event) {
+	if event.Type != termloop.EventKey {
+		return
+	}
+	switch event.Ch {
+	case 'k':
+		v.moveSelection(-1)
+	case 'j':
+		v.moveSelection(1)
+	case 't':
+		v.gameType = (v.gameType + 1) % len(gameTypes)
+		v.load()
+	case 'p':
+		v.onlyMe = !v.onlyMe
+		v.load()
+	case 'v':
+		v.version = (v.version + 1) % len(v.versions)
+		v.load()
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits of the board settings.
+const (
+	minNumCol    = 3
+	maxNumCol    = 16
+	minColDepth  = 3
+	maxColDepth  = 15
+	minColSpace  = 1
+	maxColSpace  = 4
+	minColVSpace = 0
+	maxColVSpace = 3
+
+	minCritterSize = 1
+	maxCritterSize = 3
+)
+
+// maxAliasLength is the longest player alias allowed.
+const maxAliasLength = 32
+
+// defaultLocale is the language of the game's text when none is chosen.
+const defaultLocale = "eo"
+
+// locales are the languages the game's text is available in.
+var locales = []string{"eo"}
+
+// Settings are the options chosen by the player which persist between
+// sessions.
+type Settings struct {
+	Player     string
+	NumCol     int
+	ColDepth   int
+	ColSpace   int
+	ColVSpace  int
+	SmallSize  int
+	LargeSize  int
+	Difficulty string
+	Theme      string
+	Keys       string
+	ChainSpeed string
+	Locale     string
+	Bindings   Bindings `json:",omitempty"`
+}
+
+// ReadSettings reads settings from the file at path into s.  Settings which
+// are missing from the file are left unchanged.  An error is returned if the
+// file contains unknown settings.  The settings are not validated because
+// command line flags may still override them.
+func ReadSettings(path string, s *Settings) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	err = dec.Decode(s)
+	if err != nil {
+		return fmt.Errorf("invalid settings %v: %v", path, err)
+	}
+	return nil
+}
+
+// WriteSettings writes s to a file at path.
+func WriteSettings(path string, s *Settings) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0664)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "\t")
+	err = enc.Encode(s)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Validate returns an error describing the first invalid setting in s.
+func (s *Settings) Validate() error {
+	err := validateAlias(s.Player)
+	if err != nil {
+		return err
+	}
+	err = validateRange("NumCol", s.NumCol, minNumCol, maxNumCol)
+	if err != nil {
+		return err
+	}
+	err = validateRange("ColDepth", s.ColDepth, minColDepth, maxColDepth)
+	if err != nil {
+		return err
+	}
+	err = validateRange("ColSpace", s.ColSpace, minColSpace, maxColSpace)
+	if err != nil {
+		return err
+	}
+	err = validateRange("ColVSpace", s.ColVSpace, minColVSpace, maxColVSpace)
+	if err != nil {
+		return err
+	}
+	err = validateRange("SmallSize", s.SmallSize, minCritterSize, maxCritterSize)
+	if err != nil {
+		return err
+	}
+	err = validateRange("LargeSize", s.LargeSize, minCritterSize, maxCritterSize)
+	if err != nil {
+		return err
+	}
+	if s.SmallSize > s.LargeSize {
+		return fmt.Errorf("SmallSize must not be larger than LargeSize: %d > %d", s.SmallSize, s.LargeSize)
+	}
+	if lookupDifficulty(s.Difficulty) == nil {
+		return fmt.Errorf("unknown Difficulty %q (choose from %s)", s.Difficulty, difficultyNames())
+	}
+	if lookupTheme(s.Theme) == nil {
+		return fmt.Errorf("unknown Theme %q (choose from %s)", s.Theme, themeNames())
+	}
+	if lookupChainSpeed(s.ChainSpeed) == nil {
+		return fmt.Errorf("unknown ChainSpeed %q (choose from %s)", s.ChainSpeed, chainSpeedNames())
+	}
+	_, err = buildKeyMap(s.Keys, s.Bindings)
+	if err != nil {
+		return err
+	}
+	if !validLocale(s.Locale) {
+		return fmt.Errorf("unknown Locale %q (choose from %s)", s.Locale, localeNames())
+	}
+	return nil
+}
+
+func validateRange(name string, x, min, max int) error {
+	if x < min || x > max {
+		return fmt.Errorf("%s must be between %d and %d: %d", name, min, max, x)
+	}
+	return nil
+}
+
+func validateAlias(alias string) error {
+	if alias == "" {
+		return fmt.Errorf("Player must not be empty")
+	}
+	if utf8.RuneCountInString(alias) > maxAliasLength {
+		return fmt.Errorf("Player must be at most %d characters: %q", maxAliasLength, alias)
+	}
+	for _, c := range alias {
+		if !validAliasRune(c) {
+			return fmt.Errorf("Player contains an invalid character %q: %q", c, alias)
+		}
+	}
+	return nil
+}
+
+// validAliasRune returns true if c may appear in a player alias.
+func validAliasRune(c rune) bool {
+	return c != utf8.RuneError && unicode.IsGraphic(c) && !unicode.IsSpace(c)
+}
+
+func validLocale(locale string) bool {
+	for _, l := range locales {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// localeNames returns the locales that can be chosen, separated by commas.
+func localeNames() string {
+	return strings.Join(locales, ", ")
+}
+
+// settings returns the persistent settings of conf.
+func (conf *CrunchConfig) settings() *Settings {
+	return &Settings{
+		Player:     conf.Player,
+		NumCol:     conf.NumCol,
+		ColDepth:   conf.ColDepth,
+		ColSpace:   conf.ColSpace,
+		ColVSpace:  conf.ColVSpace,
+		SmallSize:  conf.CritterSizeSmall,
+		LargeSize:  conf.CritterSizeLarge,
+		Difficulty: conf.Difficulty,
+		Theme:      conf.Theme,
+		Keys:       conf.Keys,
+		ChainSpeed: conf.ChainSpeed,
+		Locale:     conf.Locale,
+		Bindings:   conf.KeyBindings,
+	}
+}
+
+// applySettings configures conf with s.  An error is returned if s is not
+// valid, in which case conf is not modified.  Bindings in s replace the
+// bindings of the chosen Keys preset.
+func (conf *CrunchConfig) applySettings(s *Settings) error {
+	err := s.Validate()
+	if err != nil {
+		return err
+	}
+	conf.Player = s.Player
+	conf.NumCol = s.NumCol
+	conf.ColDepth = s.ColDepth
+	conf.ColSpace = s.ColSpace
+	conf.ColVSpace = s.ColVSpace
+	conf.CritterSizeSmall = s.SmallSize
+	conf.CritterSizeLarge = s.LargeSize
+	conf.Difficulty = s.Difficulty
+	conf.Survival = lookupDifficulty(s.Difficulty)
+	conf.Theme = s.Theme
+	conf.ChainSpeed = s.ChainSpeed
+	conf.Locale = s.Locale
+	conf.KeyBindings = s.Bindings
+	return conf.setKeys(s.Keys)
+}
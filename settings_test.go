@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testConfig() *CrunchConfig {
+	return &CrunchConfig{
+		Player:           "tester",
+		Survival:         lookupDifficulty(defaultDifficulty),
+		Difficulty:       defaultDifficulty,
+		Theme:            defaultTheme,
+		Keys:             defaultKeyMap,
+		ChainSpeed:       defaultChainSpeed,
+		Locale:           defaultLocale,
+		NumCol:           8,
+		ColSpace:         2,
+		ColDepth:         7,
+		CritterSizeSmall: 1,
+		CritterSizeLarge: 1,
+	}
+}
+
+func writeTestSettings(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "cimoj-settings-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "cimoj-settings.json")
+	err = ioutil.WriteFile(path, []byte(content), 0664)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSettingsFlagOverridesInvalidFile(t *testing.T) {
+	path := writeTestSettings(t, `{"NumCol": 0, "Theme": "bright"}`)
+	config := testConfig()
+	settings := config.settings()
+	err := ReadSettings(path, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The invalid value is reported unless a flag replaces it.
+	invalid := *settings
+	if testConfig().applySettings(&invalid) == nil {
+		t.Fatal("NumCol 0 accepted")
+	}
+	settings.NumCol = 10
+	err = config.applySettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.NumCol != 10 || config.Theme != "bright" {
+		t.Fatalf("settings not applied: NumCol %d Theme %q", config.NumCol, config.Theme)
+	}
+}
+
+func TestSettingsUnknownField(t *testing.T) {
+	path := writeTestSettings(t, `{"Language": "eo"}`)
+	err := ReadSettings(path, testConfig().settings())
+	if err == nil {
+		t.Fatal("unknown setting accepted")
+	}
+}
+
+func TestSettingsRoundTrip(t *testing.T) {
+	path := writeTestSettings(t, "")
+	config := testConfig()
+	config.KeyBindings = Bindings{"GrabSpit": {"x"}}
+	err := WriteSettings(path, config.settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := testConfig().settings()
+	err = ReadSettings(path, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := testConfig()
+	err = read.applySettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := read.KeyBindings["GrabSpit"]; len(b) != 1 || b[0] != "x" {
+		t.Fatalf("bindings %v", read.KeyBindings)
+	}
+}
+
+func TestSettingsLocale(t *testing.T) {
+	path := writeTestSettings(t, `{"Locale": "en"}`)
+	settings := testConfig().settings()
+	err := ReadSettings(path, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := testConfig()
+	if config.applySettings(settings) == nil {
+		t.Fatal("Locale en accepted")
+	}
+	settings.Locale = "eo"
+	err = config.applySettings(settings)
+	if err != nil || config.Locale != "eo" {
+		t.Fatalf("locale %q, error %v", config.Locale, err)
+	}
+}
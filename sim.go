@@ -0,0 +1,439 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bmatsuo/cimoj/bot"
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+// simLevel counts the bugs and items that spawned while a game was on a
+// level.  Counts are indexed by type.
+type simLevel struct {
+	Bugs  []int
+	Items []int
+}
+
+// simResult is the outcome of a simulated game.  If Survived is true the game
+// was stopped at the time limit before it ended.
+type simResult struct {
+	Seed     int64
+	Level    int
+	Score    int64
+	Time     time.Duration
+	Survived bool
+	Levels   []*simLevel
+}
+
+func (r *simResult) level(lvl int) *simLevel {
+	for len(r.Levels) <= lvl {
+		r.Levels = append(r.Levels, &simLevel{
+			Bugs:  make([]int, len(simBugTypes)),
+			Items: make([]int, len(simItemTypes)),
+		})
+	}
+	return r.Levels[lvl]
+}
+
+// simBugTypes and simItemTypes are the types of bugs and items that can
+// spawn, as reported by a simulation.
+var (
+	simBugTypes  = spawnedBugTypes()
+	simItemTypes = spawnedItemTypes()
+)
+
+func spawnedBugTypes() []crunch.BugType {
+	var types []crunch.BugType
+	for typ := crunch.BugType(0); ; typ++ {
+		_, err := typ.MarshalText()
+		if err != nil {
+			return types
+		}
+		types = append(types, typ)
+	}
+}
+
+func spawnedItemTypes() []crunch.ItemType {
+	var types []crunch.ItemType
+	for typ := crunch.ItemType(0); ; typ++ {
+		_, err := typ.MarshalText()
+		if err != nil {
+			return types
+		}
+		types = append(types, typ)
+	}
+}
+
+func indexItemType(typ crunch.ItemType) int {
+	for i := range simItemTypes {
+		if simItemTypes[i] == typ {
+			return i
+		}
+	}
+	return -1
+}
+
+// simulate plays a game with config, driven by player, until it ends or
+// limit passes on the game clock.
+func simulate(config *crunch.Config, player bot.Player, limit time.Duration) *simResult {
+	g := crunch.NewGame(config)
+	res := &simResult{Seed: config.Seed}
+
+	// The row chosen to spawn next is remembered so the types of the bugs
+	// which spawn are known even if they are eaten within the same tick.
+	next := make([]*crunch.Bug, config.NumCol)
+	for !g.Over() {
+		if time.Duration(g.Ticks())*crunch.TickDuration >= limit {
+			res.Survived = true
+			break
+		}
+		copy(next, g.Next())
+		events := g.Step(player.Play(g.Snapshot()), crunch.TickDuration)
+		mix := res.level(g.Level())
+		for _, e := range events {
+			switch e.Type {
+			case crunch.EventBugSpawned:
+				bug := next[e.Pos.X]
+				if vine := g.Vine(e.Pos.X); bug == nil && len(vine) > 0 {
+					bug = vine[0]
+				}
+				next[e.Pos.X] = nil
+				if bug != nil {
+					mix.Bugs[bug.Type]++
+				}
+			case crunch.EventItemSpawned:
+				mix.Items[indexItemType(e.Item)]++
+			case crunch.EventItemDropped:
+				// Special items were counted when they spawned on a bug.
+				// Money and poison only appear when they drop.
+				if !e.Item.IsSpecial() {
+					mix.Items[indexItemType(e.Item)]++
+				}
+			}
+		}
+	}
+	res.Level = g.Level()
+	res.Score = g.Score()
+	res.Time = time.Duration(g.Ticks()) * crunch.TickDuration
+	return res
+}
+
+// simOptions configure a simulation.
+type simOptions struct {
+	Games      int
+	Seed       int64
+	Limit      time.Duration
+	Difficulty string
+	Script     string
+	Config     crunch.Config
+}
+
+// runSimulation simulates opts.Games games, using consecutive seeds starting
+// at opts.Seed.  Games are simulated in parallel and the results are returned
+// in the order of their seeds.
+func runSimulation(opts *simOptions) []*simResult {
+	script := bot.Lookup(opts.Script)
+	results := make([]*simResult, opts.Games)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range jobs {
+				config := opts.Config
+				config.Seed = opts.Seed + int64(k)
+				results[k] = simulate(&config, script.New(config.Seed), opts.Limit)
+			}
+		}()
+	}
+	for k := range results {
+		jobs <- k
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// runSim runs the sim command with args and returns the exit status of the
+// program.  Defaults are taken from config.
+func runSim(config *CrunchConfig, args []string) int {
+	fs := flag.NewFlagSet("sim", flag.ContinueOnError)
+	games := fs.Int("n", 1000, "Nombro de ludoj")
+	seed := fs.Int64("seed", 1, "Semo de la unua ludo (la aliaj ludoj uzas la sekvajn semojn)")
+	limit := fs.Duration("limit", time.Hour, "Plej longa daŭro de ĉiu ludo")
+	difficulty := fs.String("difficulty", config.Difficulty, "Malfacileco ("+difficultyNames()+")")
+	script := fs.String("player", bot.Default, "Ludanto ("+bot.Names()+")")
+	numCol := fs.Int("numcol", config.NumCol, "Nombro de kolumnoj")
+	colDepth := fs.Int("depth", config.ColDepth, "Profundo de kolumnoj")
+	asCSV := fs.Bool("csv", false, "Raportu en CSV")
+	err := fs.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	profile := lookupDifficulty(*difficulty)
+	if profile == nil {
+		fmt.Fprintf(os.Stderr, "unknown difficulty %q (choose from %s)\n", *difficulty, difficultyNames())
+		return 2
+	}
+	if bot.Lookup(*script) == nil {
+		fmt.Fprintf(os.Stderr, "unknown player %q (choose from %s)\n", *script, bot.Names())
+		return 2
+	}
+	err = validateRange("games", *games, 1, 1<<20)
+	if err == nil {
+		err = validateRange("numcol", *numCol, minNumCol, maxNumCol)
+	}
+	if err == nil {
+		err = validateRange("depth", *colDepth, minColDepth, maxColDepth)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// The engine logs every move, which would make the log of thousands of
+	// games enormous.
+	log.SetOutput(ioutil.Discard)
+
+	opts := &simOptions{
+		Games:      *games,
+		Seed:       *seed,
+		Limit:      *limit,
+		Difficulty: profile.Name,
+		Script:     *script,
+		Config: crunch.Config{
+			NumCol:    *numCol,
+			ColDepth:  *colDepth,
+			Survival:  profile,
+			ChainStep: config.chainStep(),
+		},
+	}
+	results := runSimulation(opts)
+	if *asCSV {
+		err = writeSimCSV(os.Stdout, results)
+	} else {
+		err = writeSimText(os.Stdout, opts, results)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// simSummary aggregates the results of the games which ended on a level.
+// Times only includes the games which did not survive the time limit.
+type simSummary struct {
+	Level   int
+	Ended   int
+	Reached int
+	Scores  []int64
+	Times   []time.Duration
+	Bugs    []int
+	Items   []int
+}
+
+func summarizeLevels(results []*simResult) []*simSummary {
+	var levels []*simSummary
+	level := func(lvl int) *simSummary {
+		for len(levels) <= lvl {
+			levels = append(levels, &simSummary{
+				Level: len(levels),
+				Bugs:  make([]int, len(simBugTypes)),
+				Items: make([]int, len(simItemTypes)),
+			})
+		}
+		return levels[lvl]
+	}
+	for _, r := range results {
+		s := level(r.Level)
+		s.Ended++
+		s.Scores = append(s.Scores, r.Score)
+		if !r.Survived {
+			s.Times = append(s.Times, r.Time)
+		}
+		for lvl, mix := range r.Levels {
+			s := level(lvl)
+			for i, n := range mix.Bugs {
+				s.Bugs[i] += n
+			}
+			for i, n := range mix.Items {
+				s.Items[i] += n
+			}
+		}
+		for lvl := 0; lvl <= r.Level; lvl++ {
+			level(lvl).Reached++
+		}
+	}
+	// Games begin on level one.
+	if len(levels) > 0 && levels[0].Ended == 0 {
+		levels = levels[1:]
+	}
+	return levels
+}
+
+// percentile returns the value at fraction p of the sorted values xs.  The
+// result is zero if xs is empty.
+func percentile(xs []int64, p float64) int64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	return xs[int(p*float64(len(xs)-1)+0.5)]
+}
+
+func sortedScores(results []*simResult) []int64 {
+	xs := make([]int64, len(results))
+	for i, r := range results {
+		xs[i] = r.Score
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+	return xs
+}
+
+func sortedSeconds(times []time.Duration) []int64 {
+	xs := make([]int64, len(times))
+	for i, t := range times {
+		xs[i] = int64(t / time.Second)
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+	return xs
+}
+
+var simPercentiles = []float64{0, 0.1, 0.25, 0.5, 0.75, 0.9, 1}
+
+func writeSimText(w io.Writer, opts *simOptions, results []*simResult) error {
+	var survived int
+	var times []time.Duration
+	levels := make([]int64, len(results))
+	for i, r := range results {
+		levels[i] = int64(r.Level)
+		if r.Survived {
+			survived++
+			continue
+		}
+		times = append(times, r.Time)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "%d games on a %dx%d board, difficulty %s, player %s\n",
+		len(results), opts.Config.NumCol, opts.Config.ColDepth, opts.Difficulty, opts.Script)
+	fmt.Fprintf(w, "%d games survived the limit of %v\n\n", survived, opts.Limit)
+	fmt.Fprintf(tw, "\tmin\tp10\tp25\tp50\tp75\tp90\tmax\t\n")
+	writeSimRow(tw, "score", sortedScores(results))
+	writeSimRow(tw, "level", levels)
+	writeSimRow(tw, "death (s)", sortedSeconds(times))
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprint(tw, "level\tended\treached\t")
+	for _, typ := range simBugTypes {
+		fmt.Fprintf(tw, "%s\t", typeName(typ))
+	}
+	for _, typ := range simItemTypes {
+		fmt.Fprintf(tw, "%s\t", typeName(typ))
+	}
+	fmt.Fprintln(tw)
+	for _, s := range summarizeLevels(results) {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t", s.Level, s.Ended, s.Reached)
+		writeSimShares(tw, s.Bugs)
+		writeSimShares(tw, s.Items)
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func writeSimRow(w io.Writer, label string, xs []int64) {
+	fmt.Fprintf(w, "%s\t", label)
+	for _, p := range simPercentiles {
+		fmt.Fprintf(w, "%d\t", percentile(xs, p))
+	}
+	fmt.Fprintln(w)
+}
+
+// writeSimShares writes the share of each count as a percentage of the total.
+func writeSimShares(w io.Writer, counts []int) {
+	var total int
+	for _, n := range counts {
+		total += n
+	}
+	for _, n := range counts {
+		if total == 0 {
+			fmt.Fprint(w, "-\t")
+			continue
+		}
+		fmt.Fprintf(w, "%.1f%%\t", 100*float64(n)/float64(total))
+	}
+}
+
+func typeName(typ interface{ MarshalText() ([]byte, error) }) string {
+	name, _ := typ.MarshalText()
+	return string(name)
+}
+
+// writeSimCSV writes a row for each level giving the number of games which
+// ended on it and reached it, the distribution of the scores of the games
+// which ended on it and the times of those which died on it, and the number
+// of each type of bug and item which spawned on it.
+func writeSimCSV(w io.Writer, results []*simResult) error {
+	cw := csv.NewWriter(w)
+	header := []string{
+		"level", "ended", "reached",
+		"score_min", "score_p50", "score_max",
+		"seconds_min", "seconds_p50", "seconds_max",
+	}
+	for _, typ := range simBugTypes {
+		header = append(header, typeName(typ))
+	}
+	for _, typ := range simItemTypes {
+		header = append(header, typeName(typ))
+	}
+	err := cw.Write(header)
+	if err != nil {
+		return err
+	}
+	for _, s := range summarizeLevels(results) {
+		scores := append([]int64(nil), s.Scores...)
+		sort.Slice(scores, func(i, j int) bool { return scores[i] < scores[j] })
+		seconds := sortedSeconds(s.Times)
+		row := []string{
+			fmt.Sprint(s.Level),
+			fmt.Sprint(s.Ended),
+			fmt.Sprint(s.Reached),
+			fmt.Sprint(percentile(scores, 0)),
+			fmt.Sprint(percentile(scores, 0.5)),
+			fmt.Sprint(percentile(scores, 1)),
+			fmt.Sprint(percentile(seconds, 0)),
+			fmt.Sprint(percentile(seconds, 0.5)),
+			fmt.Sprint(percentile(seconds, 1)),
+		}
+		for _, n := range s.Bugs {
+			row = append(row, fmt.Sprint(n))
+		}
+		for _, n := range s.Items {
+			row = append(row, fmt.Sprint(n))
+		}
+		err = cw.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bmatsuo/cimoj/bot"
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+func TestSimulateItemMix(t *testing.T) {
+	config := &crunch.Config{
+		NumCol:   6,
+		ColDepth: 7,
+		Survival: lookupDifficulty(defaultDifficulty),
+		Seed:     13,
+	}
+	res := simulate(config, bot.Lookup("greedy").New(13), time.Hour)
+	if res.Score == 0 {
+		t.Fatal("the bot did not score")
+	}
+	counts := make(map[crunch.ItemType]int)
+	for _, lvl := range res.Levels {
+		for i, n := range lvl.Items {
+			counts[simItemTypes[i]] += n
+		}
+	}
+	var money, special int
+	for typ, n := range counts {
+		switch {
+		case typ.IsSpecial():
+			special += n
+		case typ != crunch.ItemPoison:
+			money += n
+		}
+	}
+	if money == 0 || special == 0 || counts[crunch.ItemPoison] == 0 {
+		t.Fatalf("items missing from the mix: %v", counts)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// chainSpeed is a named pace at which chain reactions resolve.  Step is the
+// time between the stages of a reaction.
+type chainSpeed struct {
+	Name string
+	Step time.Duration
+}
+
+// defaultChainSpeed is the name of the speed used when none is chosen.
+const defaultChainSpeed = "normal"
+
+var chainSpeeds = []*chainSpeed{
+	{"instant", 0},
+	{"fast", 60 * time.Millisecond},
+	{"normal", 120 * time.Millisecond},
+	{"slow", 250 * time.Millisecond},
+}
+
+func lookupChainSpeed(name string) *chainSpeed {
+	for _, s := range chainSpeeds {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// chainSpeedNames returns the names that can be chosen, separated by commas.
+func chainSpeedNames() string {
+	names := make([]string, len(chainSpeeds))
+	for i := range chainSpeeds {
+		names[i] = chainSpeeds[i].Name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"unicode/utf8"
+
+	"github.com/JoelOtter/termloop"
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+// sprite is the art for a bug drawn in more than one cell.  The rune '#' is
+// replaced by the bug's rune and spaces are transparent so the vine shows
+// through.
+type sprite []string
+
+// spriteRune is the placeholder in a sprite for the rune of the bug.
+const spriteRune = '#'
+
+// critterHeight returns the number of rows in the sprite of a critter size
+// cells wide.
+func critterHeight(size int) int {
+	if size > 1 {
+		return 2
+	}
+	return 1
+}
+
+// smallSprites are the sprites of small bugs indexed by CritterSizeSmall.
+// Small bugs are always a single row tall.
+var smallSprites = []sprite{
+	1: {"#"},
+	2: {"#>"},
+	3: {"<#>"},
+}
+
+// largeSprites are the sprites of large bugs and the bugs drawn like them,
+// indexed by CritterSizeLarge.
+var largeSprites = []map[crunch.BugType]sprite{
+	2: {
+		crunch.BugLarge:      {"##", "/\\"},
+		crunch.BugBomb:       {"##", "''"},
+		crunch.BugLightning:  {"##", "^^"},
+		crunch.BugRock:       {"▄▄", "▀▀"},
+		crunch.BugMagic:      {"##", "~~"},
+		crunch.BugMultiChain: {"##", "**"},
+	},
+	3: {
+		crunch.BugLarge:      {"(#)", "/ \\"},
+		crunch.BugBomb:       {"[#]", " ' "},
+		crunch.BugLightning:  {"<#>", " ^ "},
+		crunch.BugRock:       {"▄▄▄", "▀▀▀"},
+		crunch.BugMagic:      {"{#}", " ~ "},
+		crunch.BugMultiChain: {"*#*", " * "},
+	},
+}
+
+// critterSprite returns the sprite for bug.  Gnats are always drawn in a
+// single cell.
+func (conf *CrunchConfig) critterSprite(bug *crunch.Bug) sprite {
+	switch bug.Type {
+	case crunch.BugGnat:
+		return smallSprites[1]
+	case crunch.BugSmall:
+		if conf.CritterSizeSmall >= minCritterSize && conf.CritterSizeSmall < len(smallSprites) {
+			return smallSprites[conf.CritterSizeSmall]
+		}
+		return smallSprites[1]
+	}
+	if conf.CritterSizeLarge > minCritterSize && conf.CritterSizeLarge < len(largeSprites) {
+		if art, ok := largeSprites[conf.CritterSizeLarge][bug.Type]; ok {
+			return art
+		}
+	}
+	return smallSprites[1]
+}
+
+// width returns the number of columns in s.
+func (s sprite) width() int {
+	var n int
+	for _, row := range s {
+		if w := utf8.RuneCountInString(row); w > n {
+			n = w
+		}
+	}
+	return n
+}
+
+// draw renders s with its top left corner at x, y.  Every cell is drawn like
+// cell, with the placeholder replaced by cell's rune.
+func (s sprite) draw(screen *termloop.Screen, x, y int, cell *termloop.Cell) {
+	for j, row := range s {
+		var i int
+		for _, c := range row {
+			switch c {
+			case ' ':
+			case spriteRune:
+				screen.RenderCell(x+i, y+j, cell)
+			default:
+				screen.RenderCell(x+i, y+j, &termloop.Cell{Fg: cell.Fg, Bg: cell.Bg, Ch: c})
+			}
+			i++
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/JoelOtter/termloop"
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+// colorTheme is a named ColorMap the player can choose.
+type colorTheme struct {
+	Name   string
+	Colors ColorMap
+}
+
+// defaultTheme is the name of the theme used when none is chosen.
+const defaultTheme = "default"
+
+var colorThemes = []*colorTheme{
+	{"default", defaultColorMap},
+	{"bright", brightColorMap},
+	{"colorblind", colorblindColorMap},
+}
+
+func lookupTheme(name string) *colorTheme {
+	for _, t := range colorThemes {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// themeNames returns the names that can be chosen, separated by commas.
+func themeNames() string {
+	names := make([]string, len(colorThemes))
+	for i := range colorThemes {
+		names[i] = colorThemes[i].Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// brightColorMap is defaultColorMap drawn in bold, which many terminals
+// render in brighter colors.
+var brightColorMap = boldColorMap(defaultColorMap)
+
+// colorblindColorMap avoids pairing colors that are hard to tell apart with
+// red-green color blindness.
+var colorblindColorMap = simpleColorMap{
+	crunch.ColorNone:     termloop.ColorWhite,
+	crunch.ColorBg:       termloop.ColorBlack,
+	crunch.ColorMulti:    termloop.ColorWhite,
+	crunch.ColorBomb:     termloop.ColorRed | termloop.AttrBold,
+	crunch.ColorExploded: termloop.ColorBlack,
+	crunch.ColorPlayer:   termloop.ColorDefault,
+	crunch.ColorMoney:    termloop.ColorYellow,
+	crunch.ColorPoison:   termloop.ColorMagenta,
+	crunch.ColorItem:     termloop.ColorWhite,
+
+	crunch.ColorBug + 0: termloop.ColorYellow,
+	crunch.ColorBug + 1: termloop.ColorBlue,
+	crunch.ColorBug + 2: termloop.ColorWhite | termloop.AttrBold,
+	crunch.ColorBug + 3: termloop.ColorCyan,
+}
+
+func boldColorMap(m simpleColorMap) simpleColorMap {
+	bold := make(simpleColorMap, len(m))
+	for c, attr := range m {
+		bold[c] = attr
+		if crunch.Color(c) != crunch.ColorBg && crunch.Color(c) != crunch.ColorExploded {
+			bold[c] |= termloop.AttrBold
+		}
+	}
+	return bold
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/JoelOtter/termloop"
+)
+
+// VersusConfig describes the second player of a versus game, who shares the
+// keyboard with the first player unless they play on another machine.
+type VersusConfig struct {
+	Player string
+	Keys   string
+	keys   *KeyMap
+	net    *netMatch
+}
+
+// defaultVersusKeys is the name of the second player's key map when none is
+// chosen.  It shares no keys with the other presets.
+const defaultVersusKeys = "numpad"
+
+// setVersus makes games played with conf versus games against player, who
+// uses the named key map.  An error is returned if the key map shares any
+// input with the first player's key map.  Conf is unchanged if an error is
+// returned.
+func (conf *CrunchConfig) setVersus(player, keys string) error {
+	err := validateAlias(player)
+	if err != nil {
+		return err
+	}
+	m, err := buildKeyMap(keys, nil)
+	if err != nil {
+		return err
+	}
+	if b, ok := conf.keyMap().sharedBinding(m); ok {
+		return fmt.Errorf("Keys %s and %s both bind %s", conf.keyMap().Name, m.Name, b)
+	}
+	conf.Versus = &VersusConfig{
+		Player: player,
+		Keys:   keys,
+		keys:   m,
+	}
+	return nil
+}
+
+// versusSide returns the config of the game of player k in a versus game.
+// The second player's game is drawn to the right of the first.  A bot only
+// ever plays the second player.
+func (conf *CrunchConfig) versusSide(k int, seed int64) *CrunchConfig {
+	side := *conf
+	side.Seed = seed
+	side.left = k * conf.gameWidth()
+	if k == 0 {
+		side.Bot = ""
+	} else {
+		side.Player = conf.Versus.Player
+		side.Keys = conf.Versus.Keys
+		side.keys = conf.Versus.keys
+	}
+	return &side
+}
+
+// VersusGame is a game between two players sharing the terminal.  Each player
+// has their own board and big chains on one board drop garbage onto the
+// other.  The first player whose vines overflow loses.
+type VersusGame struct {
+	config  *CrunchConfig
+	games   [2]*CrunchGame
+	scoreDB ScoreDB
+
+	// ls steps both boards when the second player is on another machine.
+	// Err is the reason a networked game was abandoned.
+	ls       *lockstep
+	lastStep time.Time
+	err      error
+
+	// winner is the index of the game that was won or -1 if both players
+	// lost at once.
+	winner int
+	over   bool
+
+	scoreWriteStarted bool
+	scoreWriteResult  chan error
+	finished          bool
+}
+
+func (app *CrunchApp) createVersusGame(config *CrunchConfig) *VersusGame {
+	if config.Versus.net != nil {
+		return app.createNetGame(config)
+	}
+	v := &VersusGame{
+		config:  config,
+		scoreDB: app.scoreDB,
+		winner:  -1,
+	}
+	if config.Bot != "" {
+		// Like any game played by a bot, the game is not recorded.
+		v.scoreDB = nil
+	}
+
+	// Both boards use the same seed so the players start on equal terms.
+	seed := config.seed()
+	log.Printf("seed=%d player=%q opponent=%q new versus game", seed, config.Player, config.Versus.Player)
+	for k := range v.games {
+		v.games[k] = app.createNewGame(config.versusSide(k, seed))
+		// The game is recorded as a whole once both boards are finished.
+		v.games[k].scoreDB = nil
+	}
+	v.games[0].opponent = v.games[1]
+	v.games[1].opponent = v.games[0]
+	return v
+}
+
+// Finished will return true when the game screen can be cleared and a new game
+// can start.
+func (v *VersusGame) Finished() bool {
+	return v.finished
+}
+
+// Draw implements termloop.Drawable
+func (v *VersusGame) Draw(screen *termloop.Screen) {
+	if v.ls != nil && !v.over {
+		v.stepNet()
+	}
+	for _, g := range v.games {
+		g.Draw(screen)
+	}
+	if !v.over {
+		v.checkOver()
+	}
+	if v.over {
+		v.updateOver()
+	}
+}
+
+// Tick implements termloop.Drawable.  Every event is given to both games,
+// which only respond to the keys of their own player.
+func (v *VersusGame) Tick(event termloop.Event) {
+	if v.ls != nil {
+		v.tickNet(event)
+		return
+	}
+	for _, g := range v.games {
+		g.Tick(event)
+	}
+}
+
+// checkOver ends the game for both players once either has lost.
+func (v *VersusGame) checkOver() {
+	lost := [2]bool{v.games[0].engine.Over(), v.games[1].engine.Over()}
+	if !lost[0] && !lost[1] {
+		return
+	}
+	v.over = true
+	for k := range v.games {
+		if !lost[k] {
+			v.winner = k
+			v.games[k].engine.Win()
+		}
+	}
+	log.Printf("winner=%d versus game over", v.winner)
+	if v.ls != nil {
+		v.ls.close()
+	}
+}
+
+// updateOver records the result once both games have finished.
+func (v *VersusGame) updateOver() {
+	if v.finished || !v.games[0].Finished() || !v.games[1].Finished() {
+		return
+	}
+	if !v.scoreWriteStarted {
+		records := []*HighScore{v.games[0].calcHighScore()}
+		if v.ls == nil {
+			// The other machine records the result of a networked game for
+			// its own player.
+			records = append(records, v.games[1].calcHighScore())
+		}
+		v.scoreWriteStarted = true
+		v.scoreWriteResult = make(chan error, 1)
+		go func() {
+			if v.scoreDB == nil {
+				v.scoreWriteResult <- nil
+				return
+			}
+			for _, record := range records {
+				err := v.scoreDB.WriteHighScore(record)
+				if err != nil {
+					v.scoreWriteResult <- err
+					return
+				}
+			}
+			v.scoreWriteResult <- nil
+		}()
+		return
+	}
+	select {
+	case err := <-v.scoreWriteResult:
+		if err != nil {
+			log.Printf("unable to write high score: %v", err)
+		}
+		v.finished = true
+	default:
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JoelOtter/termloop"
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+func TestVersusBot(t *testing.T) {
+	clock := crunch.NewManualClock(time.Unix(1000, 0))
+	config := testConfig()
+	config.Clock = clock
+	config.Bot = "greedy"
+	err := config.setVersus("roboto", "numpad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &CrunchApp{config: config, scoreDB: &HighScoreFile{}}
+	v := app.createVersusGame(config)
+	if v.games[0].bot != nil || v.games[1].bot == nil {
+		t.Fatal("the bot does not play the second player")
+	}
+	if v.games[1].config.Player != "roboto" {
+		t.Fatalf("the second player is %q", v.games[1].config.Player)
+	}
+	if v.scoreDB != nil {
+		t.Fatal("a game against a bot is recorded")
+	}
+
+	// The keyboard only plays the first board.
+	clock.Advance(20 * time.Millisecond)
+	pos := [2]int{v.games[0].engine.Player().Pos(), v.games[1].engine.Player().Pos()}
+	v.Tick(termloop.Event{Type: termloop.EventKey, Ch: 'h'})
+	v.Tick(termloop.Event{Type: termloop.EventKey, Ch: '4'})
+	if v.games[0].engine.Player().Pos() != pos[0]-1 {
+		t.Fatal("the first player did not move")
+	}
+	if v.games[1].engine.Player().Pos() != pos[1] {
+		t.Fatal("the keyboard moved the bot")
+	}
+}
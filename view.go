@@ -0,0 +1,339 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/JoelOtter/termloop"
+	"github.com/bmatsuo/cimoj/crunch"
+)
+
+// vineView draws the bugs on the vines of a CrunchGame.
+type vineView struct {
+	g *CrunchGame
+}
+
+var _ termloop.Drawable = &vineView{}
+
+// Draw implements termloop.Drawable.
+func (v *vineView) Draw(screen *termloop.Screen) {
+	engine := v.g.engine
+	conf := v.g.config
+	for i := 0; i < engine.NumCol(); i++ {
+		cx := conf.colX(i)
+		for j, bug := range engine.Vine(i) {
+			// A bug that has overflowed the vine is drawn below it, signaling
+			// the player's death.
+			if j > conf.ColDepth {
+				j = conf.ColDepth
+			}
+			art := conf.critterSprite(bug)
+			// Sprites are centered on the vine and hang at the bottom of
+			// their row so bugs of different sizes line up.
+			x := cx - art.width()/2
+			y := conf.rowY(j) + conf.rowHeight() - len(art)
+			art.draw(screen, x, y, bugCell(conf.colors(), bug))
+		}
+	}
+}
+
+// Tick implements termloop.Drawable.
+func (v *vineView) Tick(event termloop.Event) {}
+
+func bugCell(colors ColorMap, bug *crunch.Bug) *termloop.Cell {
+	if bug.Exploded {
+		return &termloop.Cell{
+			Fg: colors.Color(crunch.ColorExploded),
+			Ch: bug.Rune,
+		}
+	}
+	return &termloop.Cell{
+		Fg: bugColor(colors, bug),
+		Ch: bug.Rune,
+	}
+}
+
+func bugColor(colors ColorMap, bug *crunch.Bug) termloop.Attr {
+	attr := colors.Color(bug.ColorEffective())
+	switch {
+	case len(bug.Items) > 1:
+		// Bugs holding several items are drawn in reverse so they stand out
+		// from bugs holding a single item.
+		attr |= termloop.AttrUnderline | termloop.AttrReverse
+	case len(bug.Items) == 1:
+		attr |= termloop.AttrUnderline
+	}
+	return attr
+}
+
+// previewView draws the row of bugs that spawns next above the canopy of a
+// CrunchGame, along with a bar counting down to the spawn.
+type previewView struct {
+	g *CrunchGame
+}
+
+var _ termloop.Drawable = &previewView{}
+
+// Draw implements termloop.Drawable.
+func (v *previewView) Draw(screen *termloop.Screen) {
+	engine := v.g.engine
+	conf := v.g.config
+	row := engine.Next()
+	if row == nil || engine.Over() {
+		return
+	}
+	pulled := engine.SpawnPulled()
+
+	// The preview hangs one row above the canopy until the player stomps,
+	// which pulls it down onto the canopy.
+	height := conf.rowHeight()
+	top := -height - 1
+	if pulled {
+		top = -height
+	}
+	for i, bug := range row {
+		if bug == nil {
+			continue
+		}
+		art := conf.critterSprite(bug)
+		x := conf.colX(i) - art.width()/2
+		y := top + height - len(art)
+		art.draw(screen, x, y, bugCell(conf.colors(), bug))
+	}
+
+	color := crunch.ColorPlayer
+	if pulled {
+		color = crunch.ColorBomb
+	}
+	cell := &termloop.Cell{Fg: conf.colors().Color(color), Ch: '▬'}
+	width := conf.boardSize().X
+	next, last := engine.NextSpawn()
+	n := countdownCells(engine.Now(), next, last, width)
+	for x := 0; x < n; x++ {
+		screen.RenderCell(1+x, -height-2, cell)
+	}
+}
+
+// Tick implements termloop.Drawable.
+func (v *previewView) Tick(event termloop.Event) {}
+
+// countdownCells returns the number of cells out of width filled by a bar
+// showing the time left until the next spawn.
+func countdownCells(now, next, last time.Time, width int) int {
+	if !now.Before(next) {
+		return 0
+	}
+	total := next.Sub(last)
+	if last.IsZero() || total <= 0 {
+		return width
+	}
+	n := int(math.Ceil(float64(width) * float64(next.Sub(now)) / float64(total)))
+	if n > width {
+		return width
+	}
+	return n
+}
+
+// playerView draws the player of a CrunchGame.
+type playerView struct {
+	g *CrunchGame
+}
+
+var _ termloop.Drawable = &playerView{}
+
+// Draw implements termloop.Drawable.
+func (v *playerView) Draw(screen *termloop.Screen) {
+	p := v.g.engine.Player()
+	color := crunch.ColorPlayer
+	switch {
+	case v.g.engine.Stunned():
+		color = crunch.ColorBomb
+	case v.g.engine.Poisoned():
+		color = crunch.ColorPoison
+	}
+	screen.RenderCell(v.g.config.colX(p.Pos()), v.g.config.groundY(), playerCell(v.g.config.colors(), p, color))
+}
+
+// Tick implements termloop.Drawable.
+func (v *playerView) Tick(event termloop.Event) {}
+
+// playerCell returns the cell for p drawn in color c.  The player is normally
+// drawn with ColorPlayer but a player who is hurt may be drawn differently.
+func playerCell(colors ColorMap, p *crunch.Player, c crunch.Color) *termloop.Cell {
+	cell := &termloop.Cell{}
+	if p.Contains() != nil {
+		cell.Ch = '@'
+	} else {
+		cell.Ch = 'O'
+	}
+	if p.Stomping() {
+		SetCellColorAttr(cell, colors, c, termloop.AttrUnderline)
+	} else {
+		SetCellColor(cell, colors, c)
+	}
+	return cell
+}
+
+// groundView draws the items on the ground of a CrunchGame.
+type groundView struct {
+	g     *CrunchGame
+	items []*crunch.Item
+}
+
+var _ termloop.Drawable = &groundView{}
+
+// Draw implements termloop.Drawable.
+func (v *groundView) Draw(screen *termloop.Screen) {
+	ground := v.g.engine.Ground()
+	now := v.g.engine.Now()
+	y := v.g.config.groundY()
+	for i := 0; i < v.g.config.NumCol; i++ {
+		x := v.g.config.colX(i)
+		if v.g.blasted(now, i) {
+			screen.RenderCell(x, y, &termloop.Cell{
+				Fg: v.g.config.colors().Color(crunch.ColorBomb),
+				Ch: '*',
+			})
+			continue
+		}
+		if landed := ground.Landed(i); len(landed) > 0 {
+			screen.RenderCell(x, y, bugCell(v.g.config.colors(), landed[len(landed)-1].Bug))
+			continue
+		}
+		// The items are copied so they may be sorted without disturbing the
+		// engine.
+		v.items = append(v.items[:0], ground.Slot(i)...)
+		screen.RenderCell(x, y, v.cell())
+	}
+}
+
+// Tick implements termloop.Drawable.
+func (v *groundView) Tick(event termloop.Event) {}
+
+func (v *groundView) cell() *termloop.Cell {
+	if len(v.items) == 0 {
+		return &termloop.Cell{
+			Fg: termloop.ColorWhite,
+			Bg: termloop.ColorBlack,
+			Ch: ' ',
+		}
+	}
+	return &termloop.Cell{
+		Fg: v.g.config.colors().Color(v.cellFg()),
+		Bg: v.g.config.colors().Color(v.cellBg()),
+		Ch: v.cellRune(),
+	}
+}
+
+func (v *groundView) cellRune() rune {
+	items := v.items
+	sort.Sort(&itemsByPrecedence{itemsRunePrecedence, items})
+	return itemsRunes[items[len(items)-1].Type]
+}
+
+func (v *groundView) cellFg() crunch.Color {
+	items := v.items
+	sort.Sort(&itemsByPrecedence{itemsFgPrecedence, items})
+	if len(items) == 0 {
+		return crunch.ColorItem
+	}
+	if items[0].Type.IsMoney() {
+		return crunch.ColorMoney
+	}
+	return crunch.ColorItem
+}
+
+func (v *groundView) cellBg() crunch.Color {
+	items := v.items
+	if len(items) == 1 {
+		return crunch.ColorBg
+	}
+	sort.Sort(&itemsByPrecedence{itemsBgPrecedence, items})
+	for j := range items {
+		if items[j].Type.IsPoison() {
+			return crunch.ColorPoison
+		}
+		if items[j].Type.IsMoney() {
+			return crunch.ColorMoney
+		}
+	}
+	return crunch.ColorBg
+}
+
+type itemsByPrecedence struct {
+	prec  []int
+	items []*crunch.Item
+}
+
+func (items *itemsByPrecedence) Len() int { return len(items.items) }
+func (items *itemsByPrecedence) Less(i, j int) bool {
+	return items.prec[items.items[i].Type] < items.prec[items.items[j].Type]
+}
+func (items *itemsByPrecedence) Swap(i, j int) {
+	items.items[i], items.items[j] = items.items[j], items.items[i]
+}
+
+var itemsRunePrecedence = []int{
+	crunch.ItemMoneyXXS: 1,
+	crunch.ItemMoneyXS:  2,
+	crunch.ItemMoneySM:  3,
+	crunch.ItemMoneyMD:  4,
+	crunch.ItemMoneyLG:  5,
+	crunch.ItemMoneyXL:  6,
+	crunch.ItemMoneyXXL: 7,
+	crunch.ItemPoison:   0,
+	crunch.ItemRowClear: 8,
+	crunch.ItemPushUp:   9,
+	crunch.ItemBullet:   10,
+	crunch.ItemScramble: 11,
+	crunch.ItemRecolor:  12,
+}
+
+var itemsFgPrecedence = []int{
+	crunch.ItemMoneyXXS: 6,
+	crunch.ItemMoneyXS:  7,
+	crunch.ItemMoneySM:  8,
+	crunch.ItemMoneyMD:  9,
+	crunch.ItemMoneyLG:  10,
+	crunch.ItemMoneyXL:  11,
+	crunch.ItemMoneyXXL: 12,
+	crunch.ItemPoison:   0,
+	crunch.ItemRowClear: 1,
+	crunch.ItemPushUp:   2,
+	crunch.ItemBullet:   3,
+	crunch.ItemScramble: 4,
+	crunch.ItemRecolor:  5,
+}
+
+var itemsBgPrecedence = []int{
+	crunch.ItemMoneyXXS: 6,
+	crunch.ItemMoneyXS:  7,
+	crunch.ItemMoneySM:  8,
+	crunch.ItemMoneyMD:  9,
+	crunch.ItemMoneyLG:  10,
+	crunch.ItemMoneyXL:  11,
+	crunch.ItemMoneyXXL: 12,
+	crunch.ItemPoison:   0,
+	crunch.ItemRowClear: 1,
+	crunch.ItemPushUp:   2,
+	crunch.ItemBullet:   3,
+	crunch.ItemScramble: 4,
+	crunch.ItemRecolor:  5,
+}
+
+var itemsRunes = []rune{
+	crunch.ItemMoneyXXS: '₩',
+	crunch.ItemMoneyXS:  '¢',
+	crunch.ItemMoneySM:  '$',
+	crunch.ItemMoneyMD:  '€',
+	crunch.ItemMoneyLG:  '£',
+	crunch.ItemMoneyXL:  '◇',
+	crunch.ItemMoneyXXL: 'ẘ',
+	crunch.ItemPoison:   '░',
+	crunch.ItemRowClear: '-',
+	crunch.ItemPushUp:   '^',
+	crunch.ItemBullet:   '¡',
+	crunch.ItemScramble: '#',
+	crunch.ItemRecolor:  '♥',
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountdownCells(t *testing.T) {
+	last := time.Unix(1000, 0)
+	next := last.Add(time.Second)
+	for _, test := range []struct {
+		now, next, last time.Time
+		n               int
+	}{
+		{last, next, last, 20},
+		{last.Add(time.Millisecond), next, last, 20},
+		{last.Add(time.Second / 2), next, last, 10},
+		{last.Add(time.Second/2 + time.Millisecond), next, last, 10},
+		{next.Add(-time.Millisecond), next, last, 1},
+		{next, next, last, 0},
+		{next.Add(time.Second), next, last, 0},
+
+		// Without a previous spawn, or when the spawn is overdue, the bar
+		// is full until the spawn.
+		{last, next, time.Time{}, 20},
+		{last, next, next, 20},
+		{last, next, next.Add(time.Second), 20},
+	} {
+		n := countdownCells(test.now, test.next, test.last, 20)
+		if n != test.n {
+			t.Errorf("%v before a spawn %v after the last: %d cells (expected %d)",
+				test.next.Sub(test.now), test.next.Sub(test.last), n, test.n)
+		}
+	}
+}